@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// bodyAssertion verifica o conteúdo das respostas com status esperado, de
+// acordo com -assert-body-contains e -assert-body-regex.
+type bodyAssertion struct {
+	contains string
+	regex    *regexp.Regexp
+}
+
+// newBodyAssertion compila as asserções antes do teste; devolve nil quando
+// nenhuma foi configurada.
+func newBodyAssertion(config Config) (*bodyAssertion, error) {
+	if config.AssertBodyContains == "" && config.AssertBodyRegex == "" {
+		return nil, nil
+	}
+
+	assertion := &bodyAssertion{contains: config.AssertBodyContains}
+	if config.AssertBodyRegex != "" {
+		regex, err := regexp.Compile(config.AssertBodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("expressão inválida em -assert-body-regex: %v", err)
+		}
+		assertion.regex = regex
+	}
+	return assertion, nil
+}
+
+// check devolve um erro descrevendo a primeira asserção que o body não
+// satisfaz.
+func (a *bodyAssertion) check(body []byte) error {
+	if a.contains != "" && !bytes.Contains(body, []byte(a.contains)) {
+		return fmt.Errorf("body não contém %q", a.contains)
+	}
+	if a.regex != nil && !a.regex.Match(body) {
+		return fmt.Errorf("body não corresponde a %q", a.regex)
+	}
+	return nil
+}
+
+// headerAssertion é um -assert-header: o header deve estar presente e, se um
+// valor foi informado, algum dos seus valores deve ser igual a ele ou, com o
+// prefixo "~", corresponder à expressão regular.
+type headerAssertion struct {
+	spec  string // como foi escrito na flag, para o resultado
+	name  string
+	value string
+	regex *regexp.Regexp
+}
+
+// newHeaderAssertions interpreta cada -assert-header no formato "Key",
+// "Key: valor" ou "Key: ~regex".
+func newHeaderAssertions(specs []string) ([]headerAssertion, error) {
+	var assertions []headerAssertion
+	for _, spec := range specs {
+		name, value, _ := strings.Cut(spec, ":")
+		assertion := headerAssertion{
+			spec:  strings.TrimSpace(spec),
+			name:  strings.TrimSpace(name),
+			value: strings.TrimSpace(value),
+		}
+		if assertion.name == "" {
+			return nil, fmt.Errorf("-assert-header inválido %q, use \"Key\", \"Key: valor\" ou \"Key: ~regex\"", spec)
+		}
+		if pattern, ok := strings.CutPrefix(assertion.value, "~"); ok {
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("expressão inválida em -assert-header %q: %v", spec, err)
+			}
+			assertion.regex = regex
+		}
+		assertions = append(assertions, assertion)
+	}
+	return assertions, nil
+}
+
+// check devolve um erro quando os headers da resposta não satisfazem a
+// asserção.
+func (a headerAssertion) check(header http.Header) error {
+	values := header.Values(a.name)
+	if len(values) == 0 {
+		return fmt.Errorf("header %s ausente", a.name)
+	}
+	if a.value == "" {
+		return nil
+	}
+	for _, value := range values {
+		if a.regex != nil && a.regex.MatchString(value) || a.regex == nil && value == a.value {
+			return nil
+		}
+	}
+	if a.regex != nil {
+		return fmt.Errorf("header %s: %q não corresponde a %q", a.name, values[0], a.regex)
+	}
+	return fmt.Errorf("header %s: %q, esperado %q", a.name, values[0], a.value)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHeaderAssertions(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	header.Add("X-Backend", "api-1")
+	header.Add("X-Backend", "api-2")
+
+	tests := []struct {
+		spec   string
+		passes bool
+	}{
+		{"Content-Type", true},
+		{"content-type", true},
+		{"ETag", false},
+		{"Cache-Control: no-store", true},
+		{"Cache-Control:no-store", true},
+		{"Cache-Control: no-cache", false},
+		{"Content-Type: application/json", false},
+		{"Content-Type: ~^application/json", true},
+		{"Content-Type: ~xml", false},
+		{"X-Backend: api-2", true},
+		{"X-Backend: ~^api-[0-9]+$", true},
+		{"ETag: ~.*", false},
+	}
+	for _, tt := range tests {
+		assertions, err := newHeaderAssertions([]string{tt.spec})
+		if err != nil {
+			t.Errorf("newHeaderAssertions(%q): %v", tt.spec, err)
+			continue
+		}
+		if err := assertions[0].check(header); (err == nil) != tt.passes {
+			t.Errorf("%q: erro = %v, esperado passar: %v", tt.spec, err, tt.passes)
+		}
+	}
+}
+
+func TestHeaderAssertionsInvalid(t *testing.T) {
+	for _, spec := range []string{"", ": valor", "  : x", "X-Id: ~[0-9", "X-Id: ~(a"} {
+		if _, err := newHeaderAssertions([]string{spec}); err == nil {
+			t.Errorf("newHeaderAssertions(%q) aceitou uma asserção inválida", spec)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin é a antecedência máxima com que um token é renovado
+// antes de expirar; tokens de vida curta são renovados com 10% da validade
+// restando.
+const tokenRefreshMargin = 30 * time.Second
+
+// tokenSource obtém tokens OAuth 2.0 pelo fluxo client credentials em
+// -token-endpoint e os mantém em cache, renovando-os antes da expiração ou
+// quando o servidor responde 401. É compartilhado por todos os workers.
+type tokenSource struct {
+	client       *http.Client
+	endpoint     string
+	clientID     string
+	clientSecret string
+	scope        string
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time // zero quando o servidor não informa expires_in
+	count   int64
+}
+
+// tokenResponse é a resposta de sucesso do endpoint de token (RFC 6749,
+// seção 5.1).
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// newTokenSource devolve nil sem -token-endpoint. O client do token não usa
+// -unix-socket, -host, -http3 nem cookies, que valem só para as URLs
+// testadas.
+func newTokenSource(config Config) (*tokenSource, error) {
+	if config.TokenEndpoint == "" {
+		return nil, nil
+	}
+
+	config.UnixSocket = ""
+	config.Host = ""
+	config.HTTP3 = false
+	config.EnableCookies = false
+	config.Cookies = nil
+	config.FollowRedirects = true
+	client, err := newHTTPClient(config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenSource{
+		client:       client,
+		endpoint:     config.TokenEndpoint,
+		clientID:     config.ClientID,
+		clientSecret: config.ClientSecret,
+		scope:        config.TokenScope,
+	}, nil
+}
+
+// current devolve o token em cache, obtendo um novo se ainda não houver ou
+// se ele estiver perto de expirar. As demais goroutines esperam pela
+// renovação em vez de pedir tokens em paralelo.
+func (s *tokenSource) current() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.refresh.IsZero() || time.Now().Before(s.refresh)) {
+		return s.token, nil
+	}
+	if err := s.fetch(); err != nil {
+		return "", err
+	}
+	return s.token, nil
+}
+
+// invalidate descarta o token usado numa requisição que recebeu 401. Se o
+// cache já tiver outro token, ele é a renovação pedida por outra goroutine
+// e é mantido.
+func (s *tokenSource) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// fetches informa quantos tokens foram obtidos durante o teste.
+func (s *tokenSource) fetches() int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// fetch pede um novo token. As credenciais vão no header Authorization, como
+// a RFC 6749 recomenda. Deve ser chamado com o mutex travado.
+func (s *tokenSource) fetch() error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if s.scope != "" {
+		form.Set("scope", s.scope)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("erro ao obter o token: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+
+	requested := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao obter o token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("erro ao ler a resposta do token: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("endpoint de token respondeu %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(data, &token); err != nil {
+		return fmt.Errorf("resposta do token não é um JSON válido: %v", err)
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("resposta do token sem access_token")
+	}
+
+	s.token = token.AccessToken
+	s.refresh = time.Time{}
+	if token.ExpiresIn > 0 {
+		lifetime := time.Duration(token.ExpiresIn) * time.Second
+		s.refresh = requested.Add(lifetime - min(tokenRefreshMargin, lifetime/10))
+	}
+	s.count++
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadBodyDir carrega todos os arquivos de -body-dir, em ordem alfabética,
+// como os bodies usados em rodízio pelas requisições. Arquivos .json passam
+// pela mesma leitura de -body (placeholders ${VAR} e validação do JSON); os
+// demais são enviados como estão, como em -body-raw. Tudo é lido e
+// serializado uma única vez, antes do teste.
+func loadBodyDir(config Config) (RequestBody, error) {
+	entries, err := os.ReadDir(config.BodyDir)
+	if err != nil {
+		return RequestBody{}, fmt.Errorf("erro ao ler o diretório %s: %v", config.BodyDir, err)
+	}
+
+	var body RequestBody
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(config.BodyDir, entry.Name())
+
+		variant := RequestBody{ContentType: config.ContentType}
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			// Como em -body, um arquivo com template vai como está.
+			text, err := readJSONFile(path, config.AllowMissingEnv)
+			if err != nil {
+				return body, err
+			}
+			if strings.Contains(text, "{{") {
+				variant.Data = []byte(text)
+			} else {
+				jsonBody, err := loadJSON(text)
+				if err != nil {
+					return body, err
+				}
+				if variant.Data, err = json.Marshal(jsonBody); err != nil {
+					return body, err
+				}
+			}
+			if variant.ContentType == "" {
+				variant.ContentType = "application/json"
+			}
+		} else if variant.Data, err = os.ReadFile(path); err != nil {
+			return body, fmt.Errorf("erro ao ler o arquivo %s: %v", path, err)
+		}
+
+		tmpl, err := parseTemplate("do body "+entry.Name(), string(variant.Data))
+		if err != nil {
+			return body, err
+		}
+		variant.template = tmpl
+		body.variants = append(body.variants, variant)
+	}
+
+	if len(body.variants) == 0 {
+		return body, fmt.Errorf("nenhum arquivo em %s", config.BodyDir)
+	}
+	return body, nil
+}
+
+// variant devolve o body da requisição de número index: com -body-dir, os
+// arquivos se alternam em ordem, de modo que a mesma requisição sempre
+// recebe o mesmo body; sem ele, o próprio body.
+func (b RequestBody) variant(index int) RequestBody {
+	if len(b.variants) == 0 {
+		return b
+	}
+	return b.variants[index%len(b.variants)]
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-ntlmssp"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTPClient cria o client compartilhado por todas as requisições do
+// teste, com o pool de conexões dimensionado pela concorrência. As conexões
+// abertas são registradas em conns, se não for nil.
+func newHTTPClient(config Config, conns *connCounter) (*http.Client, error) {
+	maxIdleConns := config.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = config.Concurrency
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConns
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.DisableKeepAlives = config.DisableKeepAlive
+	transport.DisableCompression = true
+
+	// O dialer substitui o do transport padrão para que -connect-timeout
+	// limite só o estabelecimento da conexão; -timeout continua valendo para
+	// a requisição inteira.
+	dialer := &net.Dialer{Timeout: config.ConnectTimeout, KeepAlive: 30 * time.Second}
+	transport.DialContext = dialer.DialContext
+	if len(config.LocalAddrs) > 0 {
+		transport.DialContext = localAddrDial(*dialer, config.LocalAddrs)
+	}
+
+	// O HTTP/2 é negociado via ALPN em conexões HTTPS. Com -http2-only o
+	// transport deixa de aceitar HTTP/1.1 e URLs http:// usam HTTP/2 sem TLS
+	// (h2c), de modo que um rebaixamento aparece como erro e não passa
+	// despercebido.
+	var protocols http.Protocols
+	switch {
+	case config.HTTP2Only:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	case config.HTTP2:
+		transport.ForceAttemptHTTP2 = true
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	default:
+		transport.ForceAttemptHTTP2 = false
+		protocols.SetHTTP1(true)
+	}
+	transport.Protocols = &protocols
+
+	// Com -unix-socket toda conexão vai para o socket; o host da URL continua
+	// sendo usado no header Host e, em HTTPS, na verificação do certificado.
+	if config.UnixSocket != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", config.UnixSocket)
+		}
+		transport.Proxy = nil
+	}
+	if conns != nil {
+		transport.DialContext = conns.wrap(transport.DialContext)
+	}
+
+	// Sem -proxy vale o Proxy do transport padrão, que lê HTTP_PROXY e
+	// HTTPS_PROXY do ambiente.
+	if config.Proxy != "" {
+		proxyURL, err := parseProxyURL(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+	}
+	if config.HTTP3 {
+		client.Transport = newHTTP3Transport(config, tlsConfig)
+	}
+
+	// O Negotiator responde aos desafios NTLM/Negotiate do servidor. Cada
+	// requisição tenta primeiro sem credenciais, o que basta numa conexão
+	// de keep-alive já autenticada; só as conexões novas pagam o handshake.
+	if config.Auth == "negotiate" {
+		client.Transport = ntlmssp.Negotiator{RoundTripper: client.Transport}
+	}
+
+	// O jar é compartilhado por todos os workers: um cookie de sessão
+	// recebido por uma requisição passa a ser enviado por todas as outras.
+	if config.EnableCookies || len(config.Cookies) > 0 {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		client.Jar = jar
+	}
+
+	// Sem seguir redirecionamentos, a resposta 3xx é devolvida como está e a
+	// latência medida é só a da primeira resposta.
+	if !config.FollowRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	return client, nil
+}
+
+// newHTTP3Transport cria o transport HTTP/3, que fala QUIC sobre UDP e não
+// recorre a TCP: um servidor sem HTTP/3 resulta em erro de conexão, não em
+// um rebaixamento silencioso. -connect-timeout limita o handshake QUIC.
+func newHTTP3Transport(config Config, tlsConfig *tls.Config) *http3.Transport {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	return &http3.Transport{
+		TLSClientConfig:    tlsConfig,
+		QUICConfig:         &quic.Config{HandshakeIdleTimeout: config.ConnectTimeout},
+		DisableCompression: true,
+	}
+}
+
+// newTLSConfig monta a configuração TLS a partir de -insecure, -client-cert,
+// -client-key, -ca-cert e -host. Só afeta conexões HTTPS; devolve nil quando
+// nenhuma dessas opções foi usada.
+func newTLSConfig(config Config) (*tls.Config, error) {
+	if !config.Insecure && config.ClientCert == "" && config.ClientKey == "" && config.CACert == "" && config.Host == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+
+	// Com -host o servidor virtual é escolhido também no SNI, e o
+	// certificado é verificado para esse nome em vez do host da URL.
+	if config.Host != "" {
+		host := config.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		tlsConfig.ServerName = host
+	}
+
+	if (config.ClientCert == "") != (config.ClientKey == "") {
+		return nil, fmt.Errorf("-client-cert e -client-key devem ser usados juntos")
+	}
+	if config.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar o certificado do cliente: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.CACert != "" {
+		data, err := os.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler o CA %s: %v", config.CACert, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("nenhum certificado PEM válido em %s", config.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// seedCookies grava os cookies de -cookie no jar para cada uma das URLs do
+// teste, como se tivessem sido recebidos delas.
+func seedCookies(jar http.CookieJar, rawURLs []string, cookies []string) error {
+	parsed := make([]*http.Cookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		name, value, ok := strings.Cut(cookie, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("cookie inválido %q, use key=value", cookie)
+		}
+		parsed = append(parsed, &http.Cookie{Name: name, Value: value})
+	}
+
+	for _, rawURL := range rawURLs {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("URL inválida %q: %v", rawURL, err)
+		}
+		jar.SetCookies(u, parsed)
+	}
+	return nil
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("URL de proxy inválida: %v", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("URL de proxy inválida %q: o esquema deve ser http, https ou socks5", raw)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("URL de proxy inválida %q: host ausente", raw)
+	}
+
+	return proxyURL, nil
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// minInterval é o menor -interval aceito. A série temporal guarda um ponto
+// por intervalo, então intervalos minúsculos em um teste longo fariam os
+// pontos crescerem sem controle.
+const minInterval = 10 * time.Millisecond
+
+// collector acumula os resultados das requisições do teste. Todo o estado é
+// protegido pelo mutex, já que várias goroutines o atualizam ao mesmo tempo.
+type collector struct {
+	mu          sync.Mutex
+	keepRecords bool
+	open        bool
+	limited     bool
+	buckets     []time.Duration
+	interval    time.Duration
+	start       time.Time
+
+	success     int64
+	failed      int64
+	retries     int64
+	redirects   int64
+	bytesRecv   int64
+	bytesDec    int64
+	bytesSent   int64
+	newConns    int64
+	reusedConns int64
+	totalTime   time.Duration
+	latency     welford
+	minDuration time.Duration
+	maxDuration time.Duration
+	durations   []time.Duration
+	ttfbs       []time.Duration
+	totalTTFB   time.Duration
+	queueDelays []time.Duration
+	withQueue   []time.Duration
+	totalQueue  time.Duration
+	sizes       []int64
+	totalSize   int64
+	statusSizes map[int]int64
+	phaseTotal  phaseDurations
+	phaseCounts [phaseCount]int64
+	statusCodes map[int]int64
+	protocols   map[string]int64
+	encodings   map[string]int64
+	failures    map[FailureKind]int64
+	headerFails map[string]int64
+	records     []RequestRecord
+	topSlow     int
+	slowest     slowHeap
+	topErrors   int
+	errorCounts map[string]int64
+	workers     []workerTotals // nil sem -per-worker-stats
+	intervals   []intervalStats
+
+	// stepOrder e steps só são usados no modo cenário.
+	stepOrder []string
+	steps     map[string]*stepStats
+}
+
+// welford calcula média e variância de forma incremental e numericamente
+// estável (algoritmo de Welford), sem precisar guardar as amostras.
+type welford struct {
+	n    int64
+	mean float64
+	m2   float64
+}
+
+func (w *welford) add(x float64) {
+	w.n++
+	delta := x - w.mean
+	w.mean += delta / float64(w.n)
+	w.m2 += delta * (x - w.mean)
+}
+
+// variance devolve a variância amostral, ou 0 com menos de duas amostras.
+func (w *welford) variance() float64 {
+	if w.n < 2 {
+		return 0
+	}
+	return w.m2 / float64(w.n-1)
+}
+
+// slowHeap é um heap de mínimo por duração: a raiz é a mais rápida das
+// requisições guardadas e a primeira a ser trocada por uma mais lenta, o que
+// mantém só as N mais lentas sem guardar todas.
+type slowHeap []SlowRequest
+
+func (h slowHeap) Len() int           { return len(h) }
+func (h slowHeap) Less(i, j int) bool { return h[i].Duration < h[j].Duration }
+func (h slowHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x any)        { *h = append(*h, x.(SlowRequest)) }
+func (h *slowHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type intervalStats struct {
+	requests  int64
+	failed    int64
+	totalTime time.Duration
+}
+
+type stepStats struct {
+	success   int64
+	failed    int64
+	totalTime time.Duration
+	durations []time.Duration
+}
+
+func newCollector(config Config) *collector {
+	return &collector{
+		keepRecords: config.CSVFile != "",
+		open:        config.Model == "open",
+		limited:     config.Model != "open" && config.RPS > 0,
+		topSlow:     config.TopSlow,
+		topErrors:   config.TopErrors,
+		errorCounts: map[string]int64{},
+		workers:     workerSlots(config),
+		buckets:     config.Buckets,
+		interval:    config.Interval,
+		durations:   make([]time.Duration, 0, config.Requests),
+		statusCodes: map[int]int64{},
+		statusSizes: map[int]int64{},
+		protocols:   map[string]int64{},
+		encodings:   map[string]int64{},
+		failures:    map[FailureKind]int64{},
+		headerFails: map[string]int64{},
+		steps:       map[string]*stepStats{},
+	}
+}
+
+// begin marca o início da fase medida, a partir do qual a série temporal é
+// contada. Deve ser chamado antes da primeira requisição medida.
+func (c *collector) begin(start time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start = start
+}
+
+// add registra o resultado da requisição de número index.
+func (c *collector) add(index int, result RequestResult, err error) {
+	completed := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.failed++
+		if c.topErrors > 0 {
+			message := normalizeError(err)
+			if _, seen := c.errorCounts[message]; !seen && len(c.errorCounts) >= maxErrorMessages {
+				message = otherErrors
+			}
+			c.errorCounts[message]++
+		}
+	} else {
+		c.success++
+	}
+
+	c.retries += int64(result.Retries)
+	c.bytesRecv += result.BytesReceived
+	c.bytesDec += result.BytesDecoded
+	c.bytesSent += result.BytesSent
+	switch result.Conn {
+	case connNew:
+		c.newConns++
+	case connReused:
+		c.reusedConns++
+	}
+
+	duration := result.Duration
+	c.totalTime += duration
+	c.latency.add(float64(duration))
+	// Sem sentinela: a primeira duração registrada define o mínimo, e um
+	// teste sem requisições reporta zero.
+	if len(c.durations) == 0 || duration < c.minDuration {
+		c.minDuration = duration
+	}
+	if duration > c.maxDuration {
+		c.maxDuration = duration
+	}
+	c.durations = append(c.durations, duration)
+
+	if result.Worker < len(c.workers) {
+		worker := &c.workers[result.Worker]
+		worker.requests++
+		if err != nil {
+			worker.failed++
+		}
+		worker.totalTime += duration
+		worker.maxDuration = max(worker.maxDuration, duration)
+	}
+
+	// Com -rps no modelo fechado a fila é a espera do worker pelo limitador,
+	// que não faz parte do tempo percebido pelo cliente.
+	if c.open || c.limited {
+		c.totalQueue += result.QueueDelay
+		c.queueDelays = append(c.queueDelays, result.QueueDelay)
+	}
+	if c.open {
+		c.withQueue = append(c.withQueue, result.QueueDelay+duration)
+	}
+
+	// Só requisições que receberam resposta entram nas métricas de TTFB.
+	if result.TTFB > 0 {
+		c.totalTTFB += result.TTFB
+		c.ttfbs = append(c.ttfbs, result.TTFB)
+	}
+	for phase, d := range result.Phases {
+		if d > 0 {
+			c.phaseTotal[phase] += d
+			c.phaseCounts[phase]++
+		}
+	}
+
+	if c.interval > 0 {
+		slot := int(completed.Sub(c.start) / c.interval)
+		for len(c.intervals) <= slot {
+			c.intervals = append(c.intervals, intervalStats{})
+		}
+		c.intervals[slot].requests++
+		c.intervals[slot].totalTime += duration
+		if err != nil {
+			c.intervals[slot].failed++
+		}
+	}
+
+	// O tamanho considerado é o do body já descomprimido, o mesmo que a
+	// aplicação cliente veria.
+	if result.StatusCode != 0 {
+		c.statusCodes[result.StatusCode]++
+		c.statusSizes[result.StatusCode] += result.BytesDecoded
+		c.totalSize += result.BytesDecoded
+		c.sizes = append(c.sizes, result.BytesDecoded)
+	}
+	if result.Protocol != "" {
+		c.protocols[result.Protocol]++
+	}
+	if result.Encoding != "" {
+		c.encodings[result.Encoding]++
+	}
+	if result.StatusCode >= 300 && result.StatusCode < 400 {
+		c.redirects++
+	}
+	if result.Failure != "" {
+		c.failures[result.Failure]++
+	}
+	if result.FailedHeader != "" {
+		c.headerFails[result.FailedHeader]++
+	}
+
+	if result.Step != "" {
+		step := c.steps[result.Step]
+		if step == nil {
+			step = &stepStats{}
+			c.steps[result.Step] = step
+		}
+		if err != nil {
+			step.failed++
+		} else {
+			step.success++
+		}
+		step.totalTime += duration
+		step.durations = append(step.durations, duration)
+	}
+
+	if c.topSlow > 0 && (len(c.slowest) < c.topSlow || duration > c.slowest[0].Duration) {
+		slow := SlowRequest{
+			Index:      index,
+			Method:     result.Method,
+			URL:        result.URL,
+			Step:       result.Step,
+			StatusCode: result.StatusCode,
+			Duration:   duration,
+		}
+		if err != nil {
+			slow.Error = err.Error()
+		}
+		if len(c.slowest) < c.topSlow {
+			heap.Push(&c.slowest, slow)
+		} else {
+			c.slowest[0] = slow
+			heap.Fix(&c.slowest, 0)
+		}
+	}
+
+	if c.keepRecords {
+		record := RequestRecord{Index: index, RequestResult: result}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		c.records = append(c.records, record)
+	}
+}
+
+// progress devolve quantas requisições já terminaram e quantas tiveram sucesso.
+func (c *collector) progress() (completed, success int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.success + c.failed, c.success
+}
+
+// results consolida as métricas coletadas. Deve ser chamado depois que todas
+// as requisições terminaram.
+func (c *collector) results() Results {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := Results{
+		TotalRequests:   c.success + c.failed,
+		SuccessRequests: c.success,
+		FailedRequests:  c.failed,
+		TotalRetries:    c.retries,
+		Redirects:       c.redirects,
+		BytesReceived:   c.bytesRecv,
+		BytesDecoded:    c.bytesDec,
+		BytesSent:       c.bytesSent,
+		NewConns:        c.newConns,
+		ReusedConns:     c.reusedConns,
+		SumDuration:     c.totalTime,
+		MinDuration:     c.minDuration,
+		MaxDuration:     c.maxDuration,
+		StatusCodes:     c.statusCodes,
+		Protocols:       c.protocols,
+		Encodings:       c.encodings,
+		Failures:        c.failures,
+		HeaderFailures:  c.headerFails,
+	}
+	if results.TotalRequests > 0 {
+		results.AverageDuration = c.totalTime / time.Duration(results.TotalRequests)
+	}
+	results.VarianceDuration = c.latency.variance()
+	results.StdDevDuration = time.Duration(math.Sqrt(results.VarianceDuration))
+
+	results.Slowest = slices.Clone(c.slowest)
+	sort.Slice(results.Slowest, func(i, j int) bool { return results.Slowest[i].Duration > results.Slowest[j].Duration })
+
+	for message, count := range c.errorCounts {
+		results.TopErrors = append(results.TopErrors, ErrorCount{Message: message, Count: count})
+	}
+	sort.Slice(results.TopErrors, func(i, j int) bool {
+		a, b := results.TopErrors[i], results.TopErrors[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Message < b.Message
+	})
+	if len(results.TopErrors) > c.topErrors {
+		results.TopErrors = results.TopErrors[:c.topErrors]
+	}
+
+	sort.Slice(c.records, func(i, j int) bool { return c.records[i].Index < c.records[j].Index })
+	results.Records = c.records
+
+	sort.Slice(c.durations, func(i, j int) bool { return c.durations[i] < c.durations[j] })
+	results.P50Duration = percentile(c.durations, 50)
+	results.P90Duration = percentile(c.durations, 90)
+	results.P95Duration = percentile(c.durations, 95)
+	results.P99Duration = percentile(c.durations, 99)
+	results.Histogram = histogram(c.durations, c.buckets)
+
+	if len(c.ttfbs) > 0 {
+		slices.Sort(c.ttfbs)
+		results.AverageTTFB = c.totalTTFB / time.Duration(len(c.ttfbs))
+		results.MinTTFB = c.ttfbs[0]
+		results.MaxTTFB = c.ttfbs[len(c.ttfbs)-1]
+		results.P50TTFB = percentile(c.ttfbs, 50)
+		results.P90TTFB = percentile(c.ttfbs, 90)
+		results.P95TTFB = percentile(c.ttfbs, 95)
+		results.P99TTFB = percentile(c.ttfbs, 99)
+	}
+
+	if len(c.queueDelays) > 0 {
+		slices.Sort(c.queueDelays)
+		results.AverageQueueDelay = c.totalQueue / time.Duration(len(c.queueDelays))
+		results.P95QueueDelay = percentile(c.queueDelays, 95)
+		results.P99QueueDelay = percentile(c.queueDelays, 99)
+		results.MaxQueueDelay = c.queueDelays[len(c.queueDelays)-1]
+	}
+	if len(c.withQueue) > 0 {
+		slices.Sort(c.withQueue)
+		results.P50WithQueue = percentile(c.withQueue, 50)
+		results.P95WithQueue = percentile(c.withQueue, 95)
+		results.P99WithQueue = percentile(c.withQueue, 99)
+	}
+
+	if len(c.sizes) > 0 {
+		slices.Sort(c.sizes)
+		results.AverageResponseSize = c.totalSize / int64(len(c.sizes))
+		results.MinResponseSize = c.sizes[0]
+		results.MaxResponseSize = c.sizes[len(c.sizes)-1]
+		results.P50ResponseSize = percentile(c.sizes, 50)
+		results.P90ResponseSize = percentile(c.sizes, 90)
+		results.P95ResponseSize = percentile(c.sizes, 95)
+		results.P99ResponseSize = percentile(c.sizes, 99)
+
+		results.StatusSizes = make(map[int]int64, len(c.statusSizes))
+		for code, total := range c.statusSizes {
+			results.StatusSizes[code] = total / c.statusCodes[code]
+		}
+	}
+
+	if results.TotalRequests > 0 {
+		results.Phases = make([]PhaseResults, phaseCount)
+		for phase := range phaseCount {
+			results.Phases[phase] = PhaseResults{Name: phaseNames[phase], Requests: c.phaseCounts[phase]}
+			if count := c.phaseCounts[phase]; count > 0 {
+				results.Phases[phase].AverageDuration = c.phaseTotal[phase] / time.Duration(count)
+			}
+		}
+	}
+
+	for i, interval := range c.intervals {
+		point := TimeSeriesPoint{
+			Offset:   time.Duration(i) * c.interval,
+			Requests: interval.requests,
+			Failed:   interval.failed,
+			RPS:      float64(interval.requests) / c.interval.Seconds(),
+		}
+		if interval.requests > 0 {
+			point.AverageDuration = interval.totalTime / time.Duration(interval.requests)
+		}
+		results.TimeSeries = append(results.TimeSeries, point)
+	}
+
+	for _, name := range c.stepOrder {
+		step := c.steps[name]
+		if step == nil {
+			step = &stepStats{}
+		}
+		stepResults := StepResults{
+			Name:            name,
+			TotalRequests:   step.success + step.failed,
+			SuccessRequests: step.success,
+			FailedRequests:  step.failed,
+		}
+		if stepResults.TotalRequests > 0 {
+			stepResults.AverageDuration = step.totalTime / time.Duration(stepResults.TotalRequests)
+		}
+		sort.Slice(step.durations, func(i, j int) bool { return step.durations[i] < step.durations[j] })
+		stepResults.P50Duration = percentile(step.durations, 50)
+		stepResults.P95Duration = percentile(step.durations, 95)
+		stepResults.P99Duration = percentile(step.durations, 99)
+		results.Steps = append(results.Steps, stepResults)
+	}
+
+	results.Workers = workerResults(c.workers)
+	return results
+}
+
+// defaultBuckets são os limites padrão do histograma, em escala logarítmica.
+var defaultBuckets = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+// histogram conta as durações (já ordenadas) em cada faixa. A última faixa,
+// com UpperBound zero, recebe o que passar do maior limite.
+func histogram(sorted []time.Duration, bounds []time.Duration) []HistogramBucket {
+	if len(bounds) == 0 {
+		bounds = defaultBuckets
+	}
+
+	buckets := make([]HistogramBucket, len(bounds)+1)
+	for i, bound := range bounds {
+		buckets[i].UpperBound = bound
+	}
+
+	i := 0
+	for _, d := range sorted {
+		for i < len(bounds) && d > bounds[i] {
+			i++
+		}
+		buckets[i].Count++
+	}
+
+	return buckets
+}
+
+// percentile calcula o percentil p (0-100) de uma lista de durações ou
+// tamanhos já ordenada, interpolando linearmente entre as duas posições
+// vizinhas.
+func percentile[T time.Duration | int64](sorted []T, p float64) T {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+
+	fraction := rank - float64(lower)
+	delta := float64(sorted[lower+1] - sorted[lower])
+	return sorted[lower] + T(fraction*delta)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCollectorConcurrentAdd registra requisições de várias goroutines ao
+// mesmo tempo, como fazem os workers, e confere os totais consolidados.
+// Rode com -race para que o detector aponte acessos fora do mutex.
+func TestCollectorConcurrentAdd(t *testing.T) {
+	const (
+		workers   = 10
+		perWorker = 101
+		total     = workers * perWorker
+	)
+
+	c := newCollector(Config{Requests: total, Concurrency: workers, PerWorkerStats: true})
+	c.begin(time.Now())
+
+	// As durações vão de 1ms a 101ms, dez de cada, e toda décima requisição
+	// falha, então os valores esperados não dependem da ordem de chegada. Com
+	// amostras repetidas os percentis caem entre valores iguais e saem exatos.
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Go(func() {
+			for i := range perWorker {
+				index := w*perWorker + i
+				result := RequestResult{
+					Worker:     w,
+					StatusCode: 200,
+					Duration:   time.Duration(index%101+1) * time.Millisecond,
+				}
+				var err error
+				if index%10 == 0 {
+					result.StatusCode = 500
+					err = errors.New("status 500")
+				}
+				c.add(index, result, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	results := c.results()
+	if results.TotalRequests != total {
+		t.Errorf("TotalRequests = %d, esperado %d", results.TotalRequests, total)
+	}
+	if results.SuccessRequests != total-total/10 {
+		t.Errorf("SuccessRequests = %d, esperado %d", results.SuccessRequests, total-total/10)
+	}
+	if results.FailedRequests != total/10 {
+		t.Errorf("FailedRequests = %d, esperado %d", results.FailedRequests, total/10)
+	}
+	if results.StatusCodes[200] != total-total/10 || results.StatusCodes[500] != total/10 {
+		t.Errorf("StatusCodes = %v", results.StatusCodes)
+	}
+
+	durations := []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"MinDuration", results.MinDuration, time.Millisecond},
+		{"MaxDuration", results.MaxDuration, 101 * time.Millisecond},
+		{"AverageDuration", results.AverageDuration, 51 * time.Millisecond},
+		{"SumDuration", results.SumDuration, total * 51 * time.Millisecond},
+		{"P50Duration", results.P50Duration, 51 * time.Millisecond},
+		{"P95Duration", results.P95Duration, 96 * time.Millisecond},
+		{"P99Duration", results.P99Duration, 100 * time.Millisecond},
+	}
+	for _, d := range durations {
+		if d.got != d.want {
+			t.Errorf("%s = %v, esperado %v", d.name, d.got, d.want)
+		}
+	}
+
+	if len(results.Workers) != workers {
+		t.Fatalf("%d workers nos resultados, esperado %d", len(results.Workers), workers)
+	}
+	for _, worker := range results.Workers {
+		if worker.Requests != perWorker {
+			t.Errorf("worker %d fez %d requisições, esperado %d", worker.Worker, worker.Requests, perWorker)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name          string
+		sorted        []time.Duration
+		p50, p95, p99 time.Duration
+	}{
+		{"vazia", nil, 0, 0, 0},
+		{"uma amostra", []time.Duration{7 * ms}, 7 * ms, 7 * ms, 7 * ms},
+		// Com 4 amostras P50 cai no meio entre a segunda e a terceira.
+		{"tamanho par", []time.Duration{10 * ms, 20 * ms, 30 * ms, 40 * ms}, 25 * ms, 38500 * time.Microsecond, 39700 * time.Microsecond},
+		// Com 5 amostras P50 é exatamente a do meio.
+		{"tamanho ímpar", []time.Duration{10 * ms, 20 * ms, 30 * ms, 40 * ms, 50 * ms}, 30 * ms, 48 * ms, 49600 * time.Microsecond},
+		{"amostras iguais", []time.Duration{5 * ms, 5 * ms, 5 * ms}, 5 * ms, 5 * ms, 5 * ms},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range []struct {
+				p    float64
+				want time.Duration
+			}{{50, tt.p50}, {95, tt.p95}, {99, tt.p99}} {
+				// A interpolação em float64 pode truncar um nanossegundo.
+				if got := percentile(tt.sorted, c.p); (got - c.want).Abs() > 1 {
+					t.Errorf("P%v = %v, esperado %v", c.p, got, c.want)
+				}
+			}
+		})
+	}
+
+	// Os tamanhos de resposta usam a mesma função com int64.
+	if got := percentile([]int64{100, 200, 300, 400}, 50); got != 250 {
+		t.Errorf("P50 dos tamanhos = %d, esperado 250", got)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+)
+
+// Códigos ANSI usados no resultado em texto.
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// palette colore trechos do resultado em texto. O valor zero não colore nada
+// e produz exatamente a saída sem cores.
+type palette struct {
+	enabled bool
+}
+
+// newPalette ativa as cores só quando o stdout é um terminal, a menos que
+// -no-color ou a variável NO_COLOR (https://no-color.org) as desliguem.
+func newPalette(config Config) palette {
+	if config.NoColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return palette{}
+	}
+	return palette{enabled: isTerminal(os.Stdout)}
+}
+
+func (p palette) paint(code, s string) string {
+	if !p.enabled {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+func (p palette) bold(s string) string   { return p.paint(ansiBold, s) }
+func (p palette) red(s string) string    { return p.paint(ansiRed, s) }
+func (p palette) green(s string) string  { return p.paint(ansiGreen, s) }
+func (p palette) yellow(s string) string { return p.paint(ansiYellow, s) }
+
+// failures colore uma contagem de falhas: vermelho quando há alguma.
+func (p palette) failures(s string, n int64) string {
+	if n == 0 {
+		return s
+	}
+	return p.red(s)
+}
+
+// rate colore a taxa de sucesso, de verde (sem falhas) a vermelho (abaixo de
+// 90%).
+func (p palette) rate(s string, rate float64) string {
+	switch {
+	case rate >= 100:
+		return p.green(s)
+	case rate >= 90:
+		return p.yellow(s)
+	default:
+		return p.red(s)
+	}
+}
+
+// status colore um status HTTP pela classe: 2xx verde, 4xx e 5xx vermelho.
+func (p palette) status(s string, code int) string {
+	switch {
+	case code >= 200 && code < 300:
+		return p.green(s)
+	case code >= 400:
+		return p.red(s)
+	default:
+		return s
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// metricDelta compara uma métrica do teste atual com a da execução de
+// referência. Delta é a variação relativa em porcentagem, ou NaN quando a
+// referência é zero.
+type metricDelta struct {
+	name           string
+	baseline       float64
+	current        float64
+	delta          float64
+	higherIsBetter bool
+	format         func(float64) string
+}
+
+// regressed informa se a métrica piorou mais que threshold por cento.
+func (m metricDelta) regressed(threshold float64) bool {
+	if math.IsNaN(m.delta) {
+		return false
+	}
+	if m.higherIsBetter {
+		return -m.delta > threshold
+	}
+	return m.delta > threshold
+}
+
+// loadBaseline lê um resultado salvo com -output json (ou -report).
+func loadBaseline(path string) (Results, error) {
+	var baseline Results
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return baseline, err
+	}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, fmt.Errorf("JSON inválido em %s: %v", path, err)
+	}
+	if baseline.TotalRequests == 0 {
+		return baseline, fmt.Errorf("%s não tem nenhuma requisição registrada", path)
+	}
+	return baseline, nil
+}
+
+func compareResults(baseline, current Results) []metricDelta {
+	duration := func(v float64) string { return time.Duration(v).String() }
+	percent := func(v float64) string { return fmt.Sprintf("%.2f%%", v) }
+	rate := func(v float64) string { return fmt.Sprintf("%.1f req/s", v) }
+
+	metrics := []metricDelta{
+		{name: "Tempo médio", baseline: float64(baseline.AverageDuration), current: float64(current.AverageDuration), format: duration},
+		{name: "P95", baseline: float64(baseline.P95Duration), current: float64(current.P95Duration), format: duration},
+		{name: "P99", baseline: float64(baseline.P99Duration), current: float64(current.P99Duration), format: duration},
+		{name: "Taxa de sucesso", baseline: successRate(baseline), current: successRate(current), higherIsBetter: true, format: percent},
+		{name: "Vazão", baseline: requestsPerSecond(baseline), current: requestsPerSecond(current), higherIsBetter: true, format: rate},
+	}
+	for i := range metrics {
+		metrics[i].delta = math.NaN()
+		if metrics[i].baseline != 0 {
+			metrics[i].delta = (metrics[i].current - metrics[i].baseline) / metrics[i].baseline * 100
+		}
+	}
+	return metrics
+}
+
+// printComparison mostra as métricas lado a lado e devolve os nomes das que
+// pioraram mais que threshold por cento.
+func printComparison(w io.Writer, label string, metrics []metricDelta, threshold float64, colors palette) []string {
+	var regressions []string
+
+	fmt.Fprintf(w, "\nComparação com %s:\n", label)
+	fmt.Fprintf(w, "  %s %s %s %s\n", pad("Métrica", -16), pad("Referência", 14), pad("Atual", 14), pad("Variação", 10))
+	for _, m := range metrics {
+		change, verdict := "-", ""
+		if !math.IsNaN(m.delta) {
+			change = fmt.Sprintf("%+.1f%%", m.delta)
+			better := m.delta < 0
+			if m.higherIsBetter {
+				better = m.delta > 0
+			}
+			switch {
+			case m.delta == 0:
+				verdict = "igual"
+			case m.regressed(threshold):
+				verdict = colors.red("REGRESSÃO")
+				regressions = append(regressions, m.name)
+			case better:
+				verdict = colors.green("melhora")
+			default:
+				verdict = colors.yellow("piora")
+			}
+		}
+		fmt.Fprintf(w, "  %s %s %s %s  %s\n", pad(m.name, -16), pad(m.format(m.baseline), 14), pad(m.format(m.current), 14), pad(change, 10), verdict)
+	}
+
+	return regressions
+}
+
+// formatRegressions descreve as métricas que ultrapassaram o limite.
+func formatRegressions(regressions []string, threshold float64) string {
+	return fmt.Sprintf("Regressão acima de %.2f%% em: %s", threshold, strings.Join(regressions, ", "))
+}
+
+// pad alinha s em width colunas contando caracteres, e não bytes como o
+// fmt, para que textos acentuados não desalinhem a tabela. Largura negativa
+// alinha à esquerda.
+func pad(s string, width int) string {
+	left := width < 0
+	if left {
+		width = -width
+	}
+	n := width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return s
+	}
+	if left {
+		return s + strings.Repeat(" ", n)
+	}
+	return strings.Repeat(" ", n) + s
+}
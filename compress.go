@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// gzipWriters reaproveita os compressores entre requisições, já que com
+// templates o body é comprimido a cada requisição.
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// compressBody devolve uma cópia do body comprimida com gzip. Bodies vazios
+// ou já comprimidos são devolvidos como estão.
+func compressBody(body RequestBody) (RequestBody, error) {
+	if len(body.Data) == 0 || body.Encoding != "" {
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	writer := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(writer)
+	writer.Reset(&buf)
+
+	if _, err := writer.Write(body.Data); err != nil {
+		return body, err
+	}
+	if err := writer.Close(); err != nil {
+		return body, err
+	}
+
+	body.Data = buf.Bytes()
+	body.Encoding = "gzip"
+	return body, nil
+}
+
+// countingReader conta os bytes lidos de r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// errBodyTooLarge indica uma resposta que passou de -max-body-size.
+var errBodyTooLarge = errors.New("resposta maior que -max-body-size")
+
+// readResponseBody copia o body da resposta para dst, descomprimindo-o quando
+// a resposta vem com Content-Encoding gzip, deflate ou br. Devolve os bytes
+// recebidos pela rede e os bytes depois da descompressão; outras codificações
+// são copiadas sem alteração. Com limit maior que zero, a leitura para ao
+// passar de limit bytes descomprimidos e devolve errBodyTooLarge; o resto do
+// body não é baixado.
+func readResponseBody(dst io.Writer, resp *http.Response, limit int64) (wire, decoded int64, err error) {
+	counter := &countingReader{r: resp.Body}
+	var src io.Reader = counter
+
+	switch responseEncoding(resp) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(counter)
+		// Respostas sem body, como as de HEAD, mantêm o header.
+		if errors.Is(err, io.EOF) {
+			return counter.n, 0, nil
+		}
+		if err != nil {
+			return counter.n, 0, err
+		}
+		defer gz.Close()
+		src = gz
+	case "deflate":
+		// O "deflate" do HTTP é o formato zlib (RFC 9110).
+		zr, err := zlib.NewReader(counter)
+		if errors.Is(err, io.EOF) {
+			return counter.n, 0, nil
+		}
+		if err != nil {
+			return counter.n, 0, err
+		}
+		defer zr.Close()
+		src = zr
+	case "br":
+		src = brotli.NewReader(counter)
+	}
+
+	if limit <= 0 {
+		decoded, err = io.Copy(dst, src)
+		return counter.n, decoded, err
+	}
+	// Só limit bytes chegam a dst e entram na contagem; um byte a mais,
+	// descartado, basta para saber que o body passou do limite.
+	decoded, err = io.Copy(dst, io.LimitReader(src, limit))
+	if err == nil && decoded == limit {
+		if n, _ := io.CopyN(io.Discard, src, 1); n > 0 {
+			err = errBodyTooLarge
+		}
+	}
+	return counter.n, decoded, err
+}
+
+// responseEncoding devolve o Content-Encoding da resposta normalizado, ou
+// vazio quando ela veio sem codificação.
+func responseEncoding(resp *http.Response) string {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "identity" {
+		return ""
+	}
+	return encoding
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stringList implementa flag.Value para flags que podem ser repetidas.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// concurrencyValue implementa flag.Value para -concurrency: além de um
+// número, aceita "auto" (um worker por CPU) e "xN" (N workers por CPU, que
+// pode ser fracionário, como x0.5). spec guarda a forma relativa usada, ou
+// fica vazio com um número fixo.
+type concurrencyValue struct {
+	value *int
+	spec  *string
+}
+
+func (c concurrencyValue) String() string {
+	if c.value == nil {
+		return ""
+	}
+	return strconv.Itoa(*c.value)
+}
+
+func (c concurrencyValue) Set(value string) error {
+	text := strings.ToLower(strings.TrimSpace(value))
+	perCPU := 0.0
+	switch {
+	case text == "auto":
+		perCPU = 1
+	case strings.HasPrefix(text, "x"):
+		n, err := strconv.ParseFloat(text[1:], 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("valor inválido %q, use um número, auto ou xN (ex: x2, x0.5)", value)
+		}
+		perCPU = n
+	default:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("valor inválido %q, use um número, auto ou xN (ex: x2, x0.5)", value)
+		}
+		*c.value, *c.spec = n, ""
+		return nil
+	}
+	*c.value = max(int(math.Round(perCPU*float64(runtime.NumCPU()))), 1)
+	*c.spec = text
+	return nil
+}
+
+// statusList implementa flag.Value para listas de status HTTP separadas por
+// vírgula, como "200,204".
+type statusList []int
+
+func (l *statusList) String() string {
+	codes := make([]string, len(*l))
+	for i, code := range *l {
+		codes[i] = strconv.Itoa(code)
+	}
+	return strings.Join(codes, ",")
+}
+
+func (l *statusList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || code < 100 || code > 599 {
+			return fmt.Errorf("status inválido %q", part)
+		}
+		*l = append(*l, code)
+	}
+	return nil
+}
+
+// intList implementa flag.Value para listas de inteiros positivos separados
+// por vírgula, como "1,10,50".
+type intList []int
+
+func (l *intList) String() string {
+	values := make([]string, len(*l))
+	for i, n := range *l {
+		values[i] = strconv.Itoa(n)
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *intList) Set(value string) error {
+	var list intList
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("valor inválido %q, use inteiros positivos", part)
+		}
+		list = append(list, n)
+	}
+	*l = list
+	return nil
+}
+
+// byteSize implementa flag.Value para tamanhos como "512KB" ou "10MB", em
+// múltiplos de 1024 como formatBytes; um número sozinho é em bytes.
+type byteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+// String usa a maior unidade que divide o tamanho sem resto, para que o
+// valor volte a ser aceito por Set.
+func (s *byteSize) String() string {
+	for _, unit := range byteUnits[:len(byteUnits)-1] {
+		if *s != 0 && int64(*s)%unit.size == 0 {
+			return strconv.FormatInt(int64(*s)/unit.size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(value string) error {
+	text := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(text, unit.suffix) {
+			text = strings.TrimSpace(strings.TrimSuffix(text, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("tamanho inválido %q, use por exemplo 512KB ou 10MB", value)
+	}
+	if n > math.MaxInt64/multiplier {
+		return fmt.Errorf("tamanho %q grande demais", value)
+	}
+	*s = byteSize(n * multiplier)
+	return nil
+}
+
+// statusRanges implementa flag.Value para faixas de status HTTP separadas
+// por vírgula, como "200-299,304" ou "2xx,304"; um código sozinho é uma faixa
+// de um só.
+type statusRanges []statusRange
+
+type statusRange struct {
+	from, to int
+}
+
+func (l *statusRanges) String() string {
+	parts := make([]string, len(*l))
+	for i, r := range *l {
+		if r.from == r.to {
+			parts[i] = strconv.Itoa(r.from)
+		} else {
+			parts[i] = fmt.Sprintf("%d-%d", r.from, r.to)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *statusRanges) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if class, ok := strings.CutSuffix(strings.ToLower(part), "xx"); ok && len(class) == 1 && class >= "1" && class <= "5" {
+			from := int(class[0]-'0') * 100
+			*l = append(*l, statusRange{from: from, to: from + 99})
+			continue
+		}
+		fromText, toText, isRange := strings.Cut(part, "-")
+		if !isRange {
+			toText = fromText
+		}
+		from, errFrom := strconv.Atoi(strings.TrimSpace(fromText))
+		to, errTo := strconv.Atoi(strings.TrimSpace(toText))
+		if errFrom != nil || errTo != nil || from < 100 || to > 599 || from > to {
+			return fmt.Errorf("faixa de status inválida %q", part)
+		}
+		*l = append(*l, statusRange{from: from, to: to})
+	}
+	return nil
+}
+
+func (l statusRanges) contains(code int) bool {
+	for _, r := range l {
+		if code >= r.from && code <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// durationList implementa flag.Value para listas de durações em ordem
+// crescente separadas por vírgula, como "10ms,50ms,100ms".
+type durationList []time.Duration
+
+func (l *durationList) String() string {
+	values := make([]string, len(*l))
+	for i, d := range *l {
+		values[i] = d.String()
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *durationList) Set(value string) error {
+	var list durationList
+	for _, part := range strings.Split(value, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || d <= 0 {
+			return fmt.Errorf("duração inválida %q", part)
+		}
+		if len(list) > 0 && d <= list[len(list)-1] {
+			return fmt.Errorf("os valores devem estar em ordem crescente")
+		}
+		list = append(list, d)
+	}
+	*l = list
+	return nil
+}
+
+// methodWeights implementa flag.Value para a lista de métodos com pesos de
+// -methods, como "GET:80,POST:20". O peso é opcional e vale 1 por padrão.
+type methodWeights []methodWeight
+
+type methodWeight struct {
+	method string
+	weight int
+}
+
+func (l *methodWeights) String() string {
+	parts := make([]string, len(*l))
+	for i, m := range *l {
+		parts[i] = fmt.Sprintf("%s:%d", m.method, m.weight)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *methodWeights) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		method, weight, hasWeight := strings.Cut(strings.TrimSpace(part), ":")
+		m := methodWeight{method: strings.ToUpper(method), weight: 1}
+		if hasWeight {
+			var err error
+			m.weight, err = strconv.Atoi(weight)
+			if err != nil || m.weight <= 0 {
+				return fmt.Errorf("peso inválido em %q", part)
+			}
+		}
+		if m.method == "" {
+			return fmt.Errorf("método vazio em %q", part)
+		}
+		*l = append(*l, m)
+	}
+	return nil
+}
+
+// loadFile lê a lista de um arquivo com uma entrada "MÉTODO:peso" por linha,
+// ignorando linhas em branco; é a implementação de -method-file.
+func (l *methodWeights) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimSpace(line); line != "" {
+			if err := l.Set(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pick sorteia um método com probabilidade proporcional ao peso.
+func (l methodWeights) pick() string {
+	total := 0
+	for _, m := range l {
+		total += m.weight
+	}
+	n := randIntN(total)
+	for _, m := range l {
+		if n < m.weight {
+			return m.method
+		}
+		n -= m.weight
+	}
+	return l[len(l)-1].method
+}
+
+// setFlags devolve os nomes das flags que já receberam um valor.
+func setFlags() map[string]bool {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// applyEnvDefaults preenche as flags que não foram passadas na linha de
+// comando com a variável de ambiente STRESS_<NOME> correspondente (ex: -url
+// usa STRESS_URL e -max-idle-conns usa STRESS_MAX_IDLE_CONNS).
+func applyEnvDefaults() error {
+	set := setFlags()
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		key := "STRESS_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value := os.Getenv(key); value != "" {
+			if setErr := flag.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("valor inválido em %s: %v", key, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// applyConfigFile preenche as flags ainda não definidas com os valores do
+// arquivo JSON, cujas chaves são os nomes das flags (ex: "concurrency": 50,
+// "rampup": "10s"). Listas são aplicadas item a item, para flags repetíveis
+// como "query". Chaves desconhecidas são reportadas como erro.
+func applyConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erro ao ler o arquivo %s: %v", path, err)
+	}
+
+	var values map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return fmt.Errorf("erro ao fazer parse de %s: %v", path, err)
+	}
+
+	var unknown []string
+	for key := range values {
+		if key == "config" || flag.Lookup(key) == nil {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("chaves desconhecidas em %s: %s", path, strings.Join(unknown, ", "))
+	}
+
+	set := setFlags()
+	for key, value := range values {
+		if set[key] {
+			continue
+		}
+
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+		for _, item := range items {
+			if _, nested := item.(map[string]any); nested {
+				return fmt.Errorf("valor inválido para %q em %s: objetos não são suportados", key, path)
+			}
+			if err := flag.Set(key, fmt.Sprint(item)); err != nil {
+				return fmt.Errorf("valor inválido para %q em %s: %v", key, path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isSet informa se a opção foi definida explicitamente, seja pela linha de
+// comando, pela variável de ambiente ou pelo arquivo de configuração.
+func isSet(name string) bool {
+	return setFlags()[name]
+}
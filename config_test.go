@@ -0,0 +1,242 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    byteSize
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"10kb", 10 << 10, false},
+		{" 2 MB ", 2 << 20, false},
+		{"1GB", 1 << 30, false},
+		{"-1KB", 0, true},
+		{"1.5MB", 0, true},
+		{"dez", 0, true},
+		{"9999999999999GB", 0, true},
+		{"9223372036854775807", 1<<63 - 1, false},
+	}
+	for _, tt := range tests {
+		var s byteSize
+		err := s.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q): erro = %v, esperado erro: %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && s != tt.want {
+			t.Errorf("Set(%q) = %d, esperado %d", tt.input, s, tt.want)
+		}
+	}
+}
+
+// TestByteSizeRoundTrip confere que String devolve um texto aceito por Set,
+// como o padrão exibido em -help e os valores lidos de -config.
+func TestByteSizeRoundTrip(t *testing.T) {
+	for _, size := range []byteSize{0, 1, 1000, 1 << 10, 1536, 10 << 20, 3 << 30} {
+		text := size.String()
+		var parsed byteSize
+		if err := parsed.Set(text); err != nil || parsed != size {
+			t.Errorf("String() = %q, que Set lê como %d (erro %v), esperado %d", text, parsed, err, size)
+		}
+	}
+}
+
+func TestStatusRanges(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    statusRanges
+		wantErr bool
+	}{
+		{"200-299", statusRanges{{200, 299}}, false},
+		{"304", statusRanges{{304, 304}}, false},
+		{"2xx,304", statusRanges{{200, 299}, {304, 304}}, false},
+		{"5XX", statusRanges{{500, 599}}, false},
+		{" 200 - 204 , 206 ", statusRanges{{200, 204}, {206, 206}}, false},
+		{"200-299,204-206", statusRanges{{200, 299}, {204, 206}}, false},
+		{"299-200", nil, true},
+		{"99-200", nil, true},
+		{"500-600", nil, true},
+		{"6xx", nil, true},
+		{"2x", nil, true},
+		{"200-", nil, true},
+		{"abc", nil, true},
+		{"200,,204", nil, true},
+	}
+	for _, tt := range tests {
+		var l statusRanges
+		err := l.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q): erro = %v, esperado erro: %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !slices.Equal(l, tt.want) {
+			t.Errorf("Set(%q) = %v, esperado %v", tt.input, l, tt.want)
+		}
+	}
+}
+
+func TestStatusRangesContains(t *testing.T) {
+	var l statusRanges
+	if err := l.Set("200-204,2xx,304"); err != nil {
+		t.Fatal(err)
+	}
+	for code, want := range map[int]bool{199: false, 200: true, 204: true, 250: true, 299: true, 300: false, 304: true, 305: false, 500: false} {
+		if got := l.contains(code); got != want {
+			t.Errorf("contains(%d) = %v, esperado %v", code, got, want)
+		}
+	}
+}
+
+func TestStatusList(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    statusList
+		wantErr bool
+	}{
+		{"200", statusList{200}, false},
+		{"200,204, 304", statusList{200, 204, 304}, false},
+		{"100,599", statusList{100, 599}, false},
+		{"99", nil, true},
+		{"600", nil, true},
+		{"200-299", nil, true},
+		{"2xx", nil, true},
+		{"", nil, true},
+		{"200,", nil, true},
+	}
+	for _, tt := range tests {
+		var l statusList
+		err := l.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q): erro = %v, esperado erro: %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !slices.Equal(l, tt.want) {
+			t.Errorf("Set(%q) = %v, esperado %v", tt.input, l, tt.want)
+		}
+	}
+}
+
+func TestConcurrencyValue(t *testing.T) {
+	cpus := runtime.NumCPU()
+	tests := []struct {
+		input    string
+		want     int
+		wantSpec string
+		wantErr  bool
+	}{
+		{"auto", cpus, "auto", false},
+		{"AUTO", cpus, "auto", false},
+		{"x2", 2 * cpus, "x2", false},
+		{"x0.5", max(int(math.Round(0.5*float64(cpus))), 1), "x0.5", false},
+		{"x0.0001", 1, "x0.0001", false},
+		{"16", 16, "", false},
+		{"x0", 0, "", true},
+		{"x-1", 0, "", true},
+		{"x", 0, "", true},
+		{"muitos", 0, "", true},
+		{"1.5", 0, "", true},
+	}
+	for _, tt := range tests {
+		value, spec := -1, "anterior"
+		err := concurrencyValue{&value, &spec}.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q): erro = %v, esperado erro: %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if value != tt.want || spec != tt.wantSpec {
+			t.Errorf("Set(%q) = %d, %q, esperado %d, %q", tt.input, value, spec, tt.want, tt.wantSpec)
+		}
+	}
+}
+
+// withFlags troca o flag.CommandLine usado por applyEnvDefaults e
+// applyConfigFile por um conjunto novo, com alguns flags como os de main,
+// já com os argumentos da linha de comando aplicados.
+func withFlags(t *testing.T, args ...string) *Config {
+	t.Helper()
+	original := flag.CommandLine
+	t.Cleanup(func() { flag.CommandLine = original })
+
+	config := &Config{}
+	flag.CommandLine = flag.NewFlagSet("stress-test-tool", flag.ContinueOnError)
+	flag.StringVar(&config.URL, "url", "http://localhost:8080/ping", "")
+	flag.StringVar(&config.Method, "method", "GET", "")
+	flag.IntVar(&config.Requests, "requests", 100, "")
+	flag.DurationVar(&config.RampUp, "rampup", 0, "")
+	flag.Var(&config.Query, "query", "")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestConfigPrecedence define o mesmo valor nas três camadas e confere a
+// ordem documentada: linha de comando, STRESS_*, -config e o padrão.
+func TestConfigPrecedence(t *testing.T) {
+	config := withFlags(t, "-url", "http://linha-de-comando")
+	t.Setenv("STRESS_URL", "http://ambiente")
+	t.Setenv("STRESS_METHOD", "POST")
+	path := writeConfigFile(t, `{"url": "http://arquivo", "method": "PUT", "requests": 7, "query": ["a=1", "b=2"]}`)
+
+	if err := applyEnvDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.URL != "http://linha-de-comando" {
+		t.Errorf("url = %q, a linha de comando deveria vencer", config.URL)
+	}
+	if config.Method != "POST" {
+		t.Errorf("method = %q, STRESS_METHOD deveria vencer o arquivo", config.Method)
+	}
+	if config.Requests != 7 {
+		t.Errorf("requests = %d, o arquivo deveria vencer o padrão", config.Requests)
+	}
+	if !slices.Equal(config.Query, stringList{"a=1", "b=2"}) {
+		t.Errorf("query = %v, esperado os dois itens do arquivo", config.Query)
+	}
+	if config.RampUp != 0 {
+		t.Errorf("rampup = %v, deveria manter o padrão", config.RampUp)
+	}
+}
+
+func TestConfigFileUnknownKey(t *testing.T) {
+	config := withFlags(t)
+	path := writeConfigFile(t, `{"url": "http://arquivo", "concurrencia": 5, "rampup": "1s"}`)
+
+	err := applyConfigFile(path)
+	if err == nil || !strings.Contains(err.Error(), "concurrencia") {
+		t.Fatalf("erro = %v, esperado apontar a chave desconhecida", err)
+	}
+	// Nada é aplicado quando o arquivo tem uma chave desconhecida.
+	if config.URL != "http://localhost:8080/ping" || config.RampUp != 0 {
+		t.Errorf("valores aplicados apesar do erro: url %q, rampup %v", config.URL, config.RampUp)
+	}
+}
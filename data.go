@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dataset são as linhas de -data, cada uma disponível nos templates da
+// requisição como .Data.
+type dataset struct {
+	rows []map[string]string
+}
+
+// loadDataset lê um CSV cuja primeira linha nomeia as variáveis. Campos entre
+// aspas seguem a RFC 4180 e todas as linhas devem ter o mesmo número de
+// colunas do cabeçalho.
+func loadDataset(path string) (*dataset, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo %s: %v", path, err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o CSV %s: %v", path, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("o CSV %s precisa de um cabeçalho e ao menos uma linha", path)
+	}
+
+	header := records[0]
+	seen := map[string]bool{}
+	for _, name := range header {
+		if name == "" {
+			return nil, fmt.Errorf("o cabeçalho do CSV %s tem uma coluna sem nome", path)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("coluna %q repetida no cabeçalho do CSV %s", name, path)
+		}
+		seen[name] = true
+	}
+
+	data := &dataset{rows: make([]map[string]string, 0, len(records)-1)}
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		data.rows = append(data.rows, row)
+	}
+
+	return data, nil
+}
+
+// row devolve a linha usada pela requisição de número index. Quando há mais
+// requisições que linhas o arquivo é percorrido de novo desde o início, de
+// modo que a mesma requisição sempre recebe a mesma linha.
+func (d *dataset) row(index int) map[string]string {
+	if d == nil {
+		return nil
+	}
+	return d.rows[index%len(d.rows)]
+}
+
+// loadURLs lê o arquivo de -urls: uma URL por linha, ignorando linhas em
+// branco e comentários iniciados por #.
+func loadURLs(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o arquivo %s: %v", path, err)
+	}
+
+	var urls []string
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("o arquivo %s não tem nenhuma URL", path)
+	}
+
+	return urls, nil
+}
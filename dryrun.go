@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+// bodyPreviewLimit é quanto do body a simulação exibe.
+const bodyPreviewLimit = 200
+
+// printDryRun exibe a configuração efetiva do teste, já com headers, body e
+// templates carregados e validados, sem enviar nenhuma requisição.
+func printDryRun(w io.Writer, config Config, headers map[string]any, body RequestBody, scenario *Scenario, data *dataset, urls []string) {
+	fmt.Fprintln(w, "=== Simulação (-dry-run): nenhuma requisição será enviada ===")
+
+	switch {
+	case scenario != nil:
+		if scenario.Sequence {
+			fmt.Fprintf(w, "Cenário: %d passos, percorridos em ordem por cada worker\n", len(scenario.Steps))
+		} else {
+			fmt.Fprintf(w, "Cenário: %d passos\n", len(scenario.Steps))
+		}
+		for _, step := range scenario.Steps {
+			fmt.Fprintf(w, "\n  %s\n", step.describe(scenario.Sequence))
+			printDryRunRequest(w, config, step.headers, step.body, "    ")
+		}
+		fmt.Fprintln(w)
+	default:
+		if len(urls) > 0 {
+			fmt.Fprintf(w, "URLs (%d, em rodízio):\n", len(urls))
+			for _, u := range urls {
+				fmt.Fprintf(w, "  %s\n", u)
+			}
+		} else {
+			fmt.Fprintf(w, "URL: %s\n", config.URL)
+		}
+		if len(config.Methods) > 0 {
+			fmt.Fprintf(w, "Métodos: %s\n", config.Methods.String())
+		} else {
+			fmt.Fprintf(w, "Método: %s\n", config.Method)
+		}
+		printDryRunRequest(w, config, headers, body, "")
+	}
+
+	if data != nil {
+		fmt.Fprintf(w, "Dados: %d linhas de %s\n", len(data.rows), config.DataFile)
+	}
+	if config.Duration > 0 {
+		fmt.Fprintf(w, "Duração: %v\n", config.Duration)
+	} else if config.RequestsPerWorker > 0 {
+		fmt.Fprintf(w, "Requisições: %d (%d por worker)\n", config.Requests, config.RequestsPerWorker)
+	} else {
+		fmt.Fprintf(w, "Requisições: %d\n", config.Requests)
+	}
+	if config.WarmupStabilize {
+		fmt.Fprintf(w, "Aquecimento: até estabilizar (tolerância %g%%, máximo %v)\n", config.StabilizeTolerance, config.MaxWarmupDuration)
+	} else if config.Warmup > 0 {
+		fmt.Fprintf(w, "Aquecimento: %d requisições\n", config.Warmup)
+	}
+	fmt.Fprintf(w, "Concorrência: %s\n", describeConcurrency(config))
+	if config.RPS > 0 {
+		fmt.Fprintf(w, "Limite de requisições por segundo: %v\n", config.RPS)
+	}
+	if len(config.LocalAddrs) > 0 {
+		fmt.Fprintf(w, "Endereços de origem: %s, em rodízio por conexão\n", config.LocalAddrs.String())
+	}
+	if config.WaitFor != "" {
+		fmt.Fprintf(w, "Antes do teste, aguarda %s responder 2xx (até %v)\n", config.WaitFor, config.WaitTimeout)
+	}
+	fmt.Fprintf(w, "Timeout: %v (conexão: %v)\n", config.Timeout, config.ConnectTimeout)
+	fmt.Fprintf(w, "Duração estimada: %s\n", estimateDuration(config))
+}
+
+func printDryRunRequest(w io.Writer, config Config, headers map[string]any, body RequestBody, indent string) {
+	fmt.Fprintf(w, "%sHeaders:\n", indent)
+	if config.Host != "" {
+		fmt.Fprintf(w, "%s  Host: %s\n", indent, config.Host)
+	}
+	if config.UserAgent != "" && !hasHeader(headers, "User-Agent") {
+		fmt.Fprintf(w, "%s  User-Agent: %s\n", indent, config.UserAgent)
+	}
+	for _, key := range slices.Sorted(maps.Keys(headers)) {
+		fmt.Fprintf(w, "%s  %s: %v\n", indent, key, headers[key])
+	}
+	if body.ContentType != "" {
+		fmt.Fprintf(w, "%s  Content-Type: %s\n", indent, body.ContentType)
+	}
+	if body.Encoding != "" {
+		fmt.Fprintf(w, "%s  Content-Encoding: %s\n", indent, body.Encoding)
+	}
+	// Credenciais não são exibidas, apenas indicadas.
+	if config.BearerToken != "" {
+		fmt.Fprintf(w, "%s  Authorization: Bearer (oculto)\n", indent)
+	}
+	if config.TokenEndpoint != "" {
+		fmt.Fprintf(w, "%s  Authorization: Bearer (obtido de %s)\n", indent, config.TokenEndpoint)
+	}
+	if config.Auth == "negotiate" {
+		fmt.Fprintf(w, "%s  Authorization: Negotiate (NTLM) como %s, senha oculta\n", indent, config.AuthUser)
+	}
+	if config.BasicUser != "" {
+		fmt.Fprintf(w, "%s  Authorization: Basic %s:(oculto)\n", indent, config.BasicUser)
+	}
+
+	switch {
+	case len(body.variants) > 0:
+		var size int
+		for _, variant := range body.variants {
+			size += len(variant.Data)
+		}
+		fmt.Fprintf(w, "%sBody: %d arquivos de -body-dir em rodízio (%d bytes no total)\n", indent, len(body.variants), size)
+	case body.multipart != nil:
+		fmt.Fprintf(w, "%sBody: multipart com %d arquivos (%d bytes)\n", indent, body.multipart.files, body.multipart.size)
+	case len(body.Data) == 0:
+		fmt.Fprintf(w, "%sBody: (vazio)\n", indent)
+	case body.Encoding != "":
+		fmt.Fprintf(w, "%sBody: %d bytes comprimidos com %s\n", indent, len(body.Data), body.Encoding)
+	default:
+		preview := string(body.Data)
+		if len(preview) > bodyPreviewLimit {
+			preview = preview[:bodyPreviewLimit] + "..."
+		}
+		kind := ""
+		if body.template != nil {
+			kind = ", template renderizado a cada requisição"
+		}
+		fmt.Fprintf(w, "%sBody (%d bytes%s): %s\n", indent, len(body.Data), kind, preview)
+	}
+}
+
+// estimateDuration estima quanto o teste vai durar a partir dos limites que
+// não dependem do servidor: a duração, o RPS e as pausas de -think-time.
+func estimateDuration(config Config) string {
+	if config.Duration > 0 {
+		return config.Duration.String()
+	}
+
+	var estimate time.Duration
+	if config.RPS > 0 {
+		estimate = time.Duration(float64(config.Requests) / config.RPS * float64(time.Second))
+	}
+	if config.ThinkTime > 0 {
+		perWorker := (config.Requests + config.Concurrency - 1) / config.Concurrency
+		estimate = max(estimate, time.Duration(perWorker-1)*config.ThinkTime)
+	}
+	if estimate == 0 {
+		return "depende da latência do servidor"
+	}
+	return fmt.Sprintf("ao menos %v, mais a latência do servidor", estimate.Round(time.Millisecond))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// secretHeaders são os headers cujo valor é uma credencial, como o
+// Authorization montado por -bearer, -basic-user e -token-endpoint.
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// failureDumper grava em disco os detalhes das respostas com falha, até o
+// limite configurado, para que possam ser reproduzidas depois.
+type failureDumper struct {
+	dir   string
+	limit int
+
+	mu    sync.Mutex
+	count int
+}
+
+// reserve ocupa uma das vagas de dump e devolve seu número, ou false quando o
+// limite já foi atingido ou o dump está desativado.
+func (d *failureDumper) reserve() (int, bool) {
+	if d == nil {
+		return 0, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.count >= d.limit {
+		return 0, false
+	}
+	d.count++
+	return d.count, true
+}
+
+// dump grava a requisição e a resposta da vaga n.
+func (d *failureDumper) dump(n int, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "=== Requisição ===\n%s %s\n", req.Method, req.URL)
+	redactHeaders(req.Header).Write(&buf)
+	fmt.Fprintf(&buf, "\n%s\n\n", reqBody)
+
+	fmt.Fprintf(&buf, "=== Resposta ===\n%s %s\n", resp.Proto, resp.Status)
+	redactHeaders(resp.Header).Write(&buf)
+	fmt.Fprintf(&buf, "\n%s\n", respBody)
+
+	name := fmt.Sprintf("failure-%04d-%d.txt", n, resp.StatusCode)
+	return os.WriteFile(filepath.Join(d.dir, name), buf.Bytes(), 0o644)
+}
+
+// redactHeaders devolve uma cópia dos headers com as credenciais ocultas,
+// como em -dry-run: do Authorization fica só o esquema ("Bearer (oculto)") e
+// os cookies somem por inteiro.
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for key, values := range redacted {
+		if !slices.Contains(secretHeaders, http.CanonicalHeaderKey(key)) {
+			continue
+		}
+		for i, value := range values {
+			if scheme, _, ok := strings.Cut(value, " "); ok && strings.HasSuffix(http.CanonicalHeaderKey(key), "Authorization") {
+				values[i] = scheme + " (oculto)"
+			} else {
+				values[i] = "(oculto)"
+			}
+		}
+	}
+	return redacted
+}
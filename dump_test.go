@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{
+		"Authorization":       {"Bearer abc.def.ghi"},
+		"Proxy-Authorization": {"Basic dXNlcjpzZW5oYQ=="},
+		"Cookie":              {"sessao=123"},
+		"Set-Cookie":          {"sessao=456; Path=/"},
+		"authorization":       {"token-sem-esquema"},
+		"X-Request-Id":        {"42"},
+	}
+
+	redacted := redactHeaders(header)
+	want := map[string]string{
+		"Authorization":       "Bearer (oculto)",
+		"Proxy-Authorization": "Basic (oculto)",
+		"Cookie":              "(oculto)",
+		"Set-Cookie":          "(oculto)",
+		"authorization":       "(oculto)",
+		"X-Request-Id":        "42",
+	}
+	for key, value := range want {
+		if got := redacted[key][0]; got != value {
+			t.Errorf("%s = %q, esperado %q", key, got, value)
+		}
+	}
+	// Os headers da requisição enviada não podem ser alterados.
+	if got := header.Get("Authorization"); got != "Bearer abc.def.ghi" {
+		t.Errorf("o original mudou: Authorization = %q", got)
+	}
+}
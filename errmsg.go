@@ -0,0 +1,40 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxErrorMessages limita quantas mensagens distintas são contadas; as que
+// chegarem depois disso entram em otherErrors, para que um erro que traz um
+// valor diferente a cada requisição não faça o mapa crescer sem limite.
+const maxErrorMessages = 1000
+
+// otherErrors agrupa as mensagens que passaram de maxErrorMessages.
+const otherErrors = "(outras mensagens)"
+
+var (
+	// O Go prefixa os erros do client com método e URL (Get "http://...":),
+	// que variam a cada requisição com templates e já aparecem em outras
+	// partes do resultado.
+	errorURLPrefix = regexp.MustCompile(`^[A-Za-z]+ "[^"]*": `)
+	errorIPv6      = regexp.MustCompile(`\[[0-9A-Fa-f:.]+(%[^\]]+)?\](:\d+)?`)
+	errorIPv4      = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`)
+)
+
+// ErrorCount é uma das mensagens de erro mais frequentes (-top-errors).
+type ErrorCount struct {
+	Message string `json:"message"`
+	Count   int64  `json:"count"`
+}
+
+// normalizeError reduz a mensagem de err a uma forma que não muda de uma
+// requisição para outra: sem o prefixo com método e URL e com endereços IP e
+// portas trocados por <endereço>. Assim "dial tcp 10.0.0.1:8080: connect:
+// connection refused" de milhares de requisições vira uma única linha.
+func normalizeError(err error) string {
+	message := errorURLPrefix.ReplaceAllString(err.Error(), "")
+	message = errorIPv6.ReplaceAllString(message, "<endereço>")
+	message = errorIPv4.ReplaceAllString(message, "<endereço>")
+	return strings.TrimSpace(message)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeError(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{
+			`Get "http://10.0.0.1:8080/ping": dial tcp 10.0.0.1:8080: connect: connection refused`,
+			"dial tcp <endereço>: connect: connection refused",
+		},
+		{
+			`Post "http://api.local/pedidos?id=42": read tcp 127.0.0.1:54321->127.0.0.1:8080: read: connection reset by peer`,
+			"read tcp <endereço>-><endereço>: read: connection reset by peer",
+		},
+		{
+			`Get "http://[::1]:8080/": dial tcp [::1]:8080: connect: connection refused`,
+			"dial tcp <endereço>: connect: connection refused",
+		},
+		{
+			"dial tcp [fe80::1%eth0]:443: i/o timeout",
+			"dial tcp <endereço>: i/o timeout",
+		},
+		{
+			`Get "http://api.local/1": context deadline exceeded (Client.Timeout exceeded while awaiting headers)`,
+			"context deadline exceeded (Client.Timeout exceeded while awaiting headers)",
+		},
+		{
+			"dial tcp: lookup api.local on 192.168.0.1:53: no such host",
+			"dial tcp: lookup api.local on <endereço>: no such host",
+		},
+		{"status 503", "status 503"},
+		{"  body não contém \"ok\"  ", "body não contém \"ok\""},
+	}
+	for _, tt := range tests {
+		if got := normalizeError(errors.New(tt.input)); got != tt.want {
+			t.Errorf("normalizeError(%q)\n obtido:   %q\n esperado: %q", tt.input, got, tt.want)
+		}
+	}
+
+	// Mensagens que só diferem no endereço ou na porta efêmera precisam
+	// virar a mesma linha em -top-errors.
+	a := normalizeError(errors.New(`Get "http://h/a": read tcp 10.1.2.3:40001->10.9.9.9:80: i/o timeout`))
+	b := normalizeError(errors.New(`Get "http://h/b": read tcp 10.1.2.4:40777->10.9.9.8:80: i/o timeout`))
+	if a != b {
+		t.Errorf("mensagens não agrupadas: %q e %q", a, b)
+	}
+}
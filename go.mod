@@ -1,3 +1,17 @@
 module github.com/viniciustneiva/stress-test-tool
 
 go 1.25.4
+
+require (
+	github.com/Azure/go-ntlmssp v0.1.1
+	github.com/andybalholm/brotli v1.2.5
+	github.com/quic-go/quic-go v0.61.0
+)
+
+require (
+	github.com/quic-go/qpack v0.6.0 // indirect
+	golang.org/x/crypto v0.54.0 // indirect
+	golang.org/x/net v0.56.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+)
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Dimensões dos gráficos SVG do relatório HTML, em pixels.
+const (
+	chartWidth  = 720
+	chartHeight = 240
+	chartMargin = 40
+)
+
+// htmlReport é o que o template do relatório HTML recebe: o resultado já
+// formatado e os gráficos prontos em SVG.
+type htmlReport struct {
+	Title     string
+	Generated string
+	Target    string
+	Summary   [][2]string
+	Histogram template.HTML
+	RPS       template.HTML
+	Latency   template.HTML
+	Statuses  [][2]string
+	Failures  [][2]string
+}
+
+// writeHTMLReport grava em path um relatório HTML autocontido: o CSS e os
+// gráficos (SVG inline) vão no próprio arquivo, que abre em qualquer navegador
+// sem acesso à rede.
+func writeHTMLReport(path string, config Config, results Results) error {
+	report := htmlReport{
+		Title:     "Relatório do stress test",
+		Generated: time.Now().Format("02/01/2006 15:04:05"),
+		Target:    fmt.Sprintf("%s %s, concorrência %d", config.Method, config.URL, config.Concurrency),
+		Histogram: histogramSVG(results.Histogram),
+	}
+	if results.Name != "" {
+		report.Title += ": " + results.Name
+	}
+	if config.ScenarioFile != "" {
+		report.Target = fmt.Sprintf("cenário %s, concorrência %d", config.ScenarioFile, config.Concurrency)
+	}
+
+	if len(results.TimeSeries) > 0 {
+		rps := make([]float64, len(results.TimeSeries))
+		latency := make([]float64, len(results.TimeSeries))
+		for i, point := range results.TimeSeries {
+			rps[i] = point.RPS
+			latency[i] = float64(point.AverageDuration) / float64(time.Millisecond)
+		}
+		report.RPS = lineSVG(results.TimeSeries, rps, "req/s", "#2563eb")
+		report.Latency = lineSVG(results.TimeSeries, latency, "ms", "#d97706")
+	}
+
+	rate := successRate(results)
+	report.Summary = [][2]string{
+		{"Total de requisições", fmt.Sprint(results.TotalRequests)},
+		{"Bem-sucedidas", fmt.Sprint(results.SuccessRequests)},
+		{"Falhadas", fmt.Sprint(results.FailedRequests)},
+		{"Taxa de sucesso", fmt.Sprintf("%.2f%%", rate)},
+		{"Tempo total", results.TotalTime.Round(time.Millisecond).String()},
+		{"Requisições por segundo", fmt.Sprintf("%.1f", requestsPerSecond(results))},
+		{"Latência média", results.AverageDuration.String()},
+		{"Mínima / máxima", fmt.Sprintf("%v / %v", results.MinDuration, results.MaxDuration)},
+		{"P50 / P90", fmt.Sprintf("%v / %v", results.P50Duration, results.P90Duration)},
+		{"P95 / P99", fmt.Sprintf("%v / %v", results.P95Duration, results.P99Duration)},
+		{"Dados recebidos", formatBytes(results.BytesReceived)},
+		{"Dados enviados", formatBytes(results.BytesSent)},
+	}
+	if results.AbortReason != "" {
+		report.Summary = append(report.Summary, [2]string{"Teste abortado", results.AbortReason})
+	}
+
+	for _, code := range slices.Sorted(maps.Keys(results.StatusCodes)) {
+		report.Statuses = append(report.Statuses, [2]string{fmt.Sprint(code), fmt.Sprint(results.StatusCodes[code])})
+	}
+	for _, kind := range slices.Sorted(maps.Keys(results.Failures)) {
+		report.Failures = append(report.Failures, [2]string{failureLabels[kind], fmt.Sprint(results.Failures[kind])})
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := htmlTemplate.Execute(file, report); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// histogramSVG desenha o histograma de latência como barras, omitindo as
+// faixas vazias das pontas, como no texto.
+func histogramSVG(buckets []HistogramBucket) template.HTML {
+	first := slices.IndexFunc(buckets, func(b HistogramBucket) bool { return b.Count > 0 })
+	if first < 0 {
+		return ""
+	}
+	last := len(buckets) - 1
+	for buckets[last].Count == 0 {
+		last--
+	}
+	shown := buckets[first : last+1]
+
+	var largest int64
+	for _, bucket := range shown {
+		largest = max(largest, bucket.Count)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" role="img" aria-label="Histograma de latência">`, chartWidth, chartHeight)
+	plotHeight := float64(chartHeight - 2*chartMargin)
+	slot := float64(chartWidth-2*chartMargin) / float64(len(shown))
+	for i, bucket := range shown {
+		label := "> " + buckets[len(buckets)-2].UpperBound.String()
+		if bucket.UpperBound > 0 {
+			label = "≤ " + bucket.UpperBound.String()
+		}
+		height := float64(bucket.Count) / float64(largest) * plotHeight
+		x := float64(chartMargin) + float64(i)*slot
+		y := float64(chartHeight-chartMargin) - height
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#2563eb"><title>%s: %d</title></rect>`,
+			x+slot*0.1, y, slot*0.8, height, template.HTMLEscapeString(label), bucket.Count)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle" class="value">%d</text>`, x+slot/2, y-4, bucket.Count)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x+slot/2, chartHeight-chartMargin+16, template.HTMLEscapeString(label))
+	}
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" class="axis"/>`, chartMargin, chartHeight-chartMargin, chartWidth-chartMargin, chartHeight-chartMargin)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// lineSVG desenha values, um por ponto da série temporal, como uma linha
+// com o eixo vertical começando em zero.
+func lineSVG(points []TimeSeriesPoint, values []float64, unit, color string) template.HTML {
+	top := slices.Max(values)
+	if top <= 0 {
+		top = 1
+	}
+
+	plotWidth := float64(chartWidth - 2*chartMargin)
+	plotHeight := float64(chartHeight - 2*chartMargin)
+	step := plotWidth
+	if len(values) > 1 {
+		step = plotWidth / float64(len(values)-1)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" role="img" aria-label="Série temporal em %s">`, chartWidth, chartHeight, unit)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" class="axis"/>`, chartMargin, chartHeight-chartMargin, chartWidth-chartMargin, chartHeight-chartMargin)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" class="axis"/>`, chartMargin, chartMargin, chartMargin, chartHeight-chartMargin)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%.1f</text>`, chartMargin-4, chartMargin+4, top)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">0</text>`, chartMargin-4, chartHeight-chartMargin)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="start">%s</text>`, 4, chartMargin-12, unit)
+
+	var path strings.Builder
+	for i, value := range values {
+		x := float64(chartMargin) + float64(i)*step
+		y := float64(chartHeight-chartMargin) - value/top*plotHeight
+		fmt.Fprintf(&path, "%.1f,%.1f ", x, y)
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"><title>%v: %.2f %s</title></circle>`, x, y, color, points[i].Offset, value, unit)
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.TrimSpace(path.String()), color)
+
+	last := points[len(points)-1].Offset
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="start">0s</text>`, chartMargin, chartHeight-chartMargin+16)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%v</text>`, chartWidth-chartMargin, chartHeight-chartMargin+16, last)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem auto; max-width: 800px; color: #1f2937; padding: 0 1rem; }
+h1 { font-size: 1.5rem; margin-bottom: 0.25rem; }
+h2 { font-size: 1.1rem; margin-top: 2rem; border-bottom: 1px solid #e5e7eb; padding-bottom: 0.25rem; }
+.meta { color: #6b7280; margin-top: 0; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: 0.3rem 0.5rem; border-bottom: 1px solid #f3f4f6; }
+td:last-child { text-align: right; font-variant-numeric: tabular-nums; }
+svg { width: 100%; height: auto; font-size: 11px; fill: #4b5563; }
+svg .axis { stroke: #9ca3af; }
+svg .value { fill: #1f2937; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Target}} · gerado em {{.Generated}}</p>
+
+<h2>Resumo</h2>
+<table>
+{{range .Summary}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+
+{{if .Histogram}}<h2>Distribuição da latência</h2>
+{{.Histogram}}
+{{end}}
+{{if .RPS}}<h2>Requisições por segundo</h2>
+{{.RPS}}
+<h2>Latência média ao longo do teste</h2>
+{{.Latency}}
+{{end}}
+{{if .Statuses}}<h2>Status HTTP</h2>
+<table>
+{{range .Statuses}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Failures}}<h2>Falhas por tipo</h2>
+<table>
+{{range .Failures}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
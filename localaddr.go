@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// checkLocalAddrs confirma que cada endereço de -local-addr é um IP desta
+// máquina em que dá para abrir conexões, antes que o teste inteiro falhe por
+// causa dele.
+func checkLocalAddrs(addrs []string) error {
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return fmt.Errorf("-local-addr %q não é um endereço IP", addr)
+		}
+		listener, err := net.Listen("tcp", net.JoinHostPort(ip.String(), "0"))
+		if err != nil {
+			return fmt.Errorf("-local-addr %s não pode ser usado nesta máquina: %v", addr, err)
+		}
+		listener.Close()
+	}
+	return nil
+}
+
+// localAddrDial devolve um DialContext que alterna entre os endereços de
+// origem a cada conexão nova. Cada endereço tem as próprias portas efêmeras,
+// então o limite de conexões simultâneas para um mesmo destino cresce com a
+// quantidade de endereços.
+func localAddrDial(base net.Dialer, addrs []string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialers := make([]*net.Dialer, len(addrs))
+	for i, addr := range addrs {
+		dialer := base
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(addr)}
+		dialers[i] = &dialer
+	}
+
+	var next atomic.Uint64
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialer := dialers[(next.Add(1)-1)%uint64(len(dialers))]
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
@@ -2,34 +2,334 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"strconv"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log"
+	"maps"
+	"math"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
+
+	"github.com/quic-go/quic-go"
 )
 
+const version = "1.0"
+
 type Config struct {
-	URL         string
-	Method      string
-	HeaderJSON  string
-	BodyJSON    string
-	Requests    int
-	Concurrency int
+	URL            string
+	Method         string
+	HeaderJSON     string
+	BodyJSON       string
+	HeaderFile     string
+	BodyFile       string
+	BodyRawFile    string
+	BodyDir        string
+	ContentType    string
+	Requests       int
+	Concurrency    int
+	Duration       time.Duration
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+	MaxBodySize    byteSize
+	Output         string
+	CSVFile        string
+	HTMLFile       string
+	RPS            float64
+	Model          string
+	RampUp         time.Duration
+
+	MaxIdleConns       int
+	DisableKeepAlive   bool
+	FreshConnections   bool
+	RequestsPerWorker  int
+	StreamJSONL        string
+	AllowMissingEnv    bool
+	MaxDuration        time.Duration
+	Name               string
+	AcceptEncoding     string
+	AssertP50          time.Duration
+	AssertP90          time.Duration
+	AssertP95          time.Duration
+	AssertP99          time.Duration
+	Insecure           bool
+	BearerToken        string
+	TokenEndpoint      string
+	ClientID           string
+	ClientSecret       string
+	TokenScope         string
+	BasicUser          string
+	BasicPass          string
+	Auth               string
+	AuthUser           string
+	AuthPass           string
+	Query              stringList
+	Warmup             int
+	WarmupStabilize    bool
+	ConcurrencySpec    string // "auto" ou "xN" quando -concurrency é relativo às CPUs
+	ConcurrencySweep   intList
+	SweepCooldown      time.Duration
+	StabilizeTolerance float64
+	MaxWarmupDuration  time.Duration
+	Retries            int
+	RetryDelay         time.Duration
+	RetryAll           bool
+	Quiet              bool
+	NoColor            bool
+	FailUnder          float64
+	Compare            string
+	RegressionLimit    float64
+	ConfigFile         string
+	ScenarioFile       string
+	DumpDir            string
+	DumpLimit          int
+	ExpectStatus       statusList
+	SuccessCodes       statusRanges
+	FollowRedirects    bool
+	Buckets            durationList
+	Interval           time.Duration
+	UserAgent          string
+	Proxy              string
+	ClientCert         string
+	ClientKey          string
+	CACert             string
+	ThinkTime          time.Duration
+	ThinkJitter        time.Duration
+	HTTP2              bool
+	HTTP2Only          bool
+	HTTP3              bool
+	DataFile           string
+	Seed               uint64
+	Report             string
+	ForceBody          bool
+	Compress           bool
+	Verbose            bool
+	AssertBodyContains string
+	AssertBodyRegex    string
+	AssertHeaders      stringList
+	EnableCookies      bool
+	Cookies            stringList
+	URLsFile           string
+	RequestsPerConn    int
+	Methods            methodWeights
+	Headers            stringList
+	DryRun             bool
+	Smoke              bool
+	MaxErrorRate       float64
+	StopOnFailure      bool
+	MinSamples         int
+	UnixSocket         string
+	WaitFor            string
+	WaitTimeout        time.Duration
+	LocalAddrs         stringList
+	Host               string
+	TopSlow            int
+	PerWorkerStats     bool
+	TopErrors          int
+	Form               stringList
+	Files              stringList
+	VeryVerbose        bool
 }
 
+// Results é serializado em JSON com as durações em nanossegundos inteiros,
+// para que ferramentas externas não precisem interpretar o formato do Go.
 type Results struct {
-	TotalRequests   int64
-	SuccessRequests int64
-	FailedRequests  int64
-	TotalTime       time.Duration
-	AverageDuration time.Duration
-	MinDuration     time.Duration
-	MaxDuration     time.Duration
+	Name                string                `json:"name,omitempty"`
+	TotalRequests       int64                 `json:"total_requests"`
+	SuccessRequests     int64                 `json:"success_requests"`
+	FailedRequests      int64                 `json:"failed_requests"`
+	TotalTime           time.Duration         `json:"total_time_ns"`
+	AverageDuration     time.Duration         `json:"average_duration_ns"`
+	SumDuration         time.Duration         `json:"sum_duration_ns"` // soma das durações de todas as requisições
+	MinDuration         time.Duration         `json:"min_duration_ns"`
+	MaxDuration         time.Duration         `json:"max_duration_ns"`
+	StdDevDuration      time.Duration         `json:"stddev_duration_ns"`
+	VarianceDuration    float64               `json:"variance_duration_ns2"` // variância amostral, em ns²
+	P50Duration         time.Duration         `json:"p50_duration_ns"`
+	P90Duration         time.Duration         `json:"p90_duration_ns"`
+	P95Duration         time.Duration         `json:"p95_duration_ns"`
+	P99Duration         time.Duration         `json:"p99_duration_ns"`
+	AverageTTFB         time.Duration         `json:"ttfb_average_ns"`
+	MinTTFB             time.Duration         `json:"ttfb_min_ns"`
+	MaxTTFB             time.Duration         `json:"ttfb_max_ns"`
+	P50TTFB             time.Duration         `json:"ttfb_p50_ns"`
+	P90TTFB             time.Duration         `json:"ttfb_p90_ns"`
+	P95TTFB             time.Duration         `json:"ttfb_p95_ns"`
+	P99TTFB             time.Duration         `json:"ttfb_p99_ns"`
+	AverageResponseSize int64                 `json:"response_size_average_bytes"`
+	MinResponseSize     int64                 `json:"response_size_min_bytes"`
+	MaxResponseSize     int64                 `json:"response_size_max_bytes"`
+	P50ResponseSize     int64                 `json:"response_size_p50_bytes"`
+	P90ResponseSize     int64                 `json:"response_size_p90_bytes"`
+	P95ResponseSize     int64                 `json:"response_size_p95_bytes"`
+	P99ResponseSize     int64                 `json:"response_size_p99_bytes"`
+	StatusCodes         map[int]int64         `json:"status_codes"`
+	StatusSizes         map[int]int64         `json:"response_size_average_by_status"`
+	Protocols           map[string]int64      `json:"protocols"`
+	Encodings           map[string]int64      `json:"content_encodings"`
+	Failures            map[FailureKind]int64 `json:"failures"`
+	HeaderFailures      map[string]int64      `json:"header_assertion_failures,omitempty"`
+	TotalRetries        int64                 `json:"total_retries"`
+	TokenRefreshes      int64                 `json:"token_refreshes"`
+	Model               string                `json:"model"`
+	RPSLimit            float64               `json:"rps_limit,omitempty"`
+	AverageQueueDelay   time.Duration         `json:"queue_delay_average_ns,omitempty"`
+	P95QueueDelay       time.Duration         `json:"queue_delay_p95_ns,omitempty"`
+	P99QueueDelay       time.Duration         `json:"queue_delay_p99_ns,omitempty"`
+	MaxQueueDelay       time.Duration         `json:"queue_delay_max_ns,omitempty"`
+	Unsent              int64                 `json:"unsent_requests,omitempty"`
+	P50WithQueue        time.Duration         `json:"p50_duration_with_queue_ns,omitempty"`
+	P95WithQueue        time.Duration         `json:"p95_duration_with_queue_ns,omitempty"`
+	P99WithQueue        time.Duration         `json:"p99_duration_with_queue_ns,omitempty"`
+	PeakGoroutines      int                   `json:"peak_goroutines,omitempty"`
+	PeakConns           int64                 `json:"peak_connections,omitempty"`
+	PeakHeap            uint64                `json:"peak_heap_bytes,omitempty"`
+	Redirects           int64                 `json:"redirects"`
+	BytesReceived       int64                 `json:"bytes_received"`
+	BytesDecoded        int64                 `json:"bytes_decompressed"`
+	BytesSent           int64                 `json:"bytes_sent"`
+	NewConns            int64                 `json:"new_connections"`
+	ReusedConns         int64                 `json:"reused_connections"`
+	Interrupted         bool                  `json:"interrupted"`
+	AbortReason         string                `json:"abort_reason,omitempty"`
+	RunLimit            time.Duration         `json:"max_run_duration_ns,omitempty"` // -max-duration
+	RunLimitReached     bool                  `json:"max_run_duration_reached"`
+	Seed                uint64                `json:"seed"`
+	Steps               []StepResults         `json:"steps,omitempty"`
+	Histogram           []HistogramBucket     `json:"histogram"`
+	TimeSeries          []TimeSeriesPoint     `json:"time_series"`
+	Phases              []PhaseResults        `json:"phases"`
+	Slowest             []SlowRequest         `json:"slowest,omitempty"`
+	TopErrors           []ErrorCount          `json:"top_errors,omitempty"`
+	Workers             []WorkerStats         `json:"workers,omitempty"`
+
+	// Records só é preenchido quando a exportação em CSV está ativa.
+	Records []RequestRecord `json:"-"`
+}
+
+// HistogramBucket conta as requisições com duração até UpperBound (e acima
+// do limite da faixa anterior). UpperBound zero indica a faixa sem limite.
+type HistogramBucket struct {
+	UpperBound time.Duration `json:"upper_bound_ns"`
+	Count      int64         `json:"count"`
+}
+
+// TimeSeriesPoint resume as requisições concluídas em um intervalo do teste,
+// que começa em Offset a partir do início da fase medida.
+type TimeSeriesPoint struct {
+	Offset          time.Duration `json:"offset_ns"`
+	Requests        int64         `json:"requests"`
+	Failed          int64         `json:"failed"`
+	RPS             float64       `json:"rps"`
+	AverageDuration time.Duration `json:"average_duration_ns"`
+}
+
+// StepResults resume as métricas de um passo do cenário.
+type StepResults struct {
+	Name            string        `json:"name"`
+	TotalRequests   int64         `json:"total_requests"`
+	SuccessRequests int64         `json:"success_requests"`
+	FailedRequests  int64         `json:"failed_requests"`
+	AverageDuration time.Duration `json:"average_duration_ns"`
+	P50Duration     time.Duration `json:"p50_duration_ns"`
+	P95Duration     time.Duration `json:"p95_duration_ns"`
+	P99Duration     time.Duration `json:"p99_duration_ns"`
+}
+
+// PhaseResults é o tempo médio de uma fase das requisições, calculado só
+// sobre as requisições em que ela aconteceu.
+type PhaseResults struct {
+	Name            string        `json:"name"`
+	Requests        int64         `json:"requests"`
+	AverageDuration time.Duration `json:"average_duration_ns"`
+}
+
+// SlowRequest é uma das requisições mais lentas do teste (-top-slow).
+type SlowRequest struct {
+	Index      int           `json:"index"`
+	Method     string        `json:"method"`
+	URL        string        `json:"url"`
+	Step       string        `json:"step,omitempty"`
+	StatusCode int           `json:"status_code"`
+	Duration   time.Duration `json:"duration_ns"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// FailureKind classifica o motivo pelo qual uma requisição falhou.
+type FailureKind string
+
+const (
+	FailureTimeout    FailureKind = "timeout"
+	FailureDNS        FailureKind = "dns"
+	FailureConnection FailureKind = "connection"
+	FailureStatus     FailureKind = "status"
+	FailureAssertion  FailureKind = "assertion"
+	FailureHeader     FailureKind = "header"
+	FailureFileLimit  FailureKind = "file_limit"
+	FailureTooLarge   FailureKind = "too_large"
+	FailureOther      FailureKind = "other"
+)
+
+// failureKinds define a ordem em que as falhas são exibidas.
+var failureKinds = []FailureKind{FailureTimeout, FailureDNS, FailureConnection, FailureStatus, FailureHeader, FailureAssertion, FailureFileLimit, FailureTooLarge, FailureOther}
+
+var failureLabels = map[FailureKind]string{
+	FailureTimeout:    "Timeout",
+	FailureDNS:        "Falha de DNS",
+	FailureConnection: "Erro de conexão",
+	FailureStatus:     "Status inesperado",
+	FailureAssertion:  "Body inesperado",
+	FailureHeader:     "Header inesperado",
+	FailureFileLimit:  "Limite de arquivos abertos",
+	FailureTooLarge:   "Resposta grande demais",
+	FailureOther:      "Outros erros",
+}
+
+// RequestResult guarda o que foi observado em uma única requisição.
+type RequestResult struct {
+	Start         time.Time
+	Duration      time.Duration
+	TTFB          time.Duration // zero quando nenhuma resposta chegou
+	Phases        phaseDurations
+	Conn          connReuse
+	Encoding      string // Content-Encoding da resposta
+	Method        string
+	URL           string
+	StatusCode    int
+	Protocol      string
+	Failure       FailureKind
+	BytesReceived int64
+	BytesDecoded  int64
+	BytesSent     int64
+	Retries       int
+	Step          string
+	QueueDelay    time.Duration // atraso entre o horário agendado e o disparo (modelo aberto) ou espera pelo limitador de -rps
+	Worker        int
+	FailedHeader  string // o -assert-header que a resposta não satisfez
+}
+
+// RequestRecord é uma linha da exportação por requisição.
+type RequestRecord struct {
+	Index int
+	RequestResult
+	Error string
 }
 
 func loadJSON(jsonStr string) (map[string]any, error) {
@@ -46,20 +346,401 @@ func loadJSON(jsonStr string) (map[string]any, error) {
 	return result, nil
 }
 
-func makeRequest(config Config, headers map[string]any, body map[string]any) (time.Duration, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+func loadJSONOrFile(jsonStr, path string, allowMissingEnv bool) (map[string]any, error) {
+	if path != "" {
+		return loadJSONFile(path, allowMissingEnv)
+	}
+	return loadJSON(jsonStr)
+}
+
+func loadJSONFile(path string, allowMissingEnv bool) (map[string]any, error) {
+	text, err := readJSONFile(path, allowMissingEnv)
+	if err != nil {
+		return nil, err
+	}
+	return loadJSON(text)
+}
+
+// readJSONFile lê o arquivo e expande os placeholders ${VAR} antes do parse,
+// para que segredos fiquem no ambiente e não no arquivo versionado.
+func readJSONFile(path string, allowMissingEnv bool) (string, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return "", err
+	}
+
+	expanded, err := expandEnv(string(data), allowMissingEnv)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	return expanded, nil
+}
+
+// readInput lê o arquivo, ou o stdin inteiro quando path é "-". Como o stdin
+// só pode ser lido uma vez, o conteúdo fica em memória e é reaproveitado em
+// todas as requisições.
+func readInput(path string) ([]byte, error) {
+	if path != "-" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler o arquivo %s: %v", path, err)
+		}
+		return data, nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o stdin: %v", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("nada foi recebido pelo stdin")
+	}
+	return data, nil
+}
+
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv troca cada ${VAR} pelo valor da variável de ambiente, escapado
+// como conteúdo de string JSON para que aspas ou barras no valor não quebrem
+// o arquivo. Só a forma com chaves é reconhecida, já que um $ solto é comum
+// em valores JSON. Variáveis não definidas são erro, a menos que
+// allowMissing as trate como vazias.
+func expandEnv(text string, allowMissing bool) (string, error) {
+	var missing []string
+	expanded := envPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok && !allowMissing {
+			missing = append(missing, name)
+		}
+		return jsonEscape(value)
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("variáveis de ambiente não definidas: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// RequestBody é o body enviado em todas as requisições, já serializado.
+// Quando contém placeholders, template é renderizado a cada requisição.
+type RequestBody struct {
+	Data        []byte
+	ContentType string
+	Encoding    string // Content-Encoding de Data, preenchido por -compress
+	template    *template.Template
+	multipart   *multipartBody // usado no lugar de Data com -file
+	variants    []RequestBody  // bodies de -body-dir, usados em rodízio
+}
+
+// size devolve quantos bytes o body ocupa na requisição.
+func (b RequestBody) size() int64 {
+	if b.multipart != nil {
+		return b.multipart.size
+	}
+	return int64(len(b.Data))
+}
+
+// loadBody monta o body a partir do arquivo bruto (-body-raw), enviado sem
+// alterações, dos arquivos de -file, dos campos de -form ou do JSON (-body /
+// STRESS_BODY_JSON). -content-type substitui o Content-Type em todos os
+// casos, menos no multipart, que depende do boundary.
+func loadBody(config Config) (RequestBody, error) {
+	if config.BodyDir != "" {
+		return loadBodyDir(config)
+	}
+
+	body := RequestBody{ContentType: config.ContentType}
+
+	// Com -file os campos de -form viram partes do multipart.
+	if len(config.Files) > 0 {
+		multipart, err := newMultipartBody(config.Form, config.Files)
+		if err != nil {
+			return body, err
+		}
+		body.multipart = multipart
+		body.ContentType = multipart.contentType
+		return body, nil
+	}
+
+	if len(config.Form) > 0 {
+		values := url.Values{}
+		for _, field := range config.Form {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || key == "" {
+				return body, fmt.Errorf("campo de formulário inválido %q, use key=value", field)
+			}
+			values.Add(key, value)
+		}
+		body.Data = []byte(values.Encode())
+		if body.ContentType == "" {
+			body.ContentType = "application/x-www-form-urlencoded"
+		}
+		return body, nil
+	}
+
+	if config.BodyRawFile != "" {
+		data, err := readInput(config.BodyRawFile)
+		if err != nil {
+			return body, err
+		}
+		body.Data = data
+		return body, parseBodyTemplate(&body)
+	}
+
+	text := config.BodyJSON
+	if config.BodyFile != "" {
+		var err error
+		text, err = readJSONFile(config.BodyFile, config.AllowMissingEnv)
+		if err != nil {
+			return body, err
+		}
+	}
+	// Um body com template vai como o texto do arquivo, renderizado a cada
+	// requisição: reserializado, as aspas dentro das ações seriam escapadas
+	// e o template deixaria de ser válido.
+	if strings.Contains(text, "{{") {
+		body.Data = []byte(text)
+		if body.ContentType == "" {
+			body.ContentType = "application/json"
+		}
+		return body, parseBodyTemplate(&body)
+	}
+
+	jsonBody, err := loadJSON(text)
+	if err != nil {
+		return body, err
+	}
+	// Com -force-body um arquivo de body vazio ou com {} envia {}, em vez
+	// de nenhum body.
+	hasJSON := config.BodyFile != "" || config.BodyJSON != ""
+	if len(jsonBody) > 0 || (config.ForceBody && hasJSON) {
+		body.Data, err = json.Marshal(jsonBody)
+		if err != nil {
+			return body, err
+		}
+		if body.ContentType == "" {
+			body.ContentType = "application/json"
+		}
+	}
+
+	return body, parseBodyTemplate(&body)
+}
+
+// requester reúne o que é compartilhado por todas as requisições de um teste.
+type requester struct {
+	client   *http.Client
+	dumper   *failureDumper
+	urls     map[string]*template.Template
+	sequence *atomic.Int64
+
+	// logger registra cada tentativa com -verbose; headers inclui os
+	// headers no registro (-vv).
+	logger  *log.Logger
+	headers bool
+
+	assertion        *bodyAssertion
+	headerAssertions []headerAssertion
+
+	// tokens fornece o token Bearer de -token-endpoint, ou é nil sem ele.
+	tokens *tokenSource
+
+	// conns conta as conexões abertas, para o pico exibido no resultado.
+	conns *connCounter
+
+	// capture guarda a última requisição e resposta completas, com -smoke.
+	capture *smokeCapture
+
+	// Com -requests-per-conn cada worker usa sua própria conexão;
+	// connRequests conta quantas requisições ela já atendeu.
+	perConn      int
+	connRequests int
+}
+
+func newRequester(config Config) (*requester, error) {
+	conns := &connCounter{}
+	client, err := newHTTPClient(config, conns)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, err := newBodyAssertion(config)
+	if err != nil {
+		return nil, err
+	}
+
+	headerAssertions, err := newHeaderAssertions(config.AssertHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := newTokenSource(config)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &requester{
+		client:           client,
+		sequence:         &atomic.Int64{},
+		logger:           newRequestLogger(config),
+		headers:          config.VeryVerbose,
+		assertion:        assertion,
+		headerAssertions: headerAssertions,
+		tokens:           tokens,
+		conns:            conns,
+	}
+	if config.DumpDir != "" {
+		r.dumper = &failureDumper{dir: config.DumpDir, limit: config.DumpLimit}
+	}
+	return r, nil
+}
+
+// forWorker devolve uma cópia do requester com um pool de conexões só seu,
+// para que o limite de -requests-per-conn valha por conexão. A cópia só pode
+// ser usada por uma goroutine; templates, dumps e cookies continuam
+// compartilhados.
+func (r *requester) forWorker(perConn int) *requester {
+	transport := r.client.Transport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 1
+	transport.MaxIdleConnsPerHost = 1
+
+	client := *r.client
+	client.Transport = transport
+
+	worker := *r
+	worker.client = &client
+	worker.perConn = perConn
+	worker.connRequests = 0
+	return &worker
+}
+
+// makeRequest envia a requisição e, se configurado, a repete em erros de
+// conexão e respostas 5xx. O resultado devolvido é o da última tentativa.
+// row é a linha de -data usada nos templates, ou nil sem -data. O
+// cancelamento de ctx interrompe a espera de -retry-delay, e a última
+// tentativa feita é devolvida.
+func (r *requester) makeRequest(ctx context.Context, config Config, headers map[string]any, body RequestBody, row map[string]string) (RequestResult, error) {
+	canRetry := config.RetryAll || idempotentMethods[strings.ToUpper(config.Method)]
+
+	// Os templates são renderizados uma vez por requisição; as novas
+	// tentativas reenviam a mesma URL e o mesmo body.
+	if r.hasTemplate(config, body) {
+		var err error
+		config, body, err = r.renderRequest(config, body, newTemplateVars(r.sequence.Add(1), row))
+		if err != nil {
+			return RequestResult{Start: time.Now(), Failure: FailureOther}, err
+		}
+	}
+
+	// Bodies fixos já chegam comprimidos de main; só os renderizados acima
+	// precisam ser comprimidos aqui.
+	if config.Compress {
+		var err error
+		body, err = compressBody(body)
+		if err != nil {
+			return RequestResult{Start: time.Now(), Failure: FailureOther}, fmt.Errorf("erro ao comprimir o body: %v", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		result, err := r.sendAuthorized(config, headers, body)
+		result.Retries = attempt
+		if attempt >= config.Retries || !canRetry || !shouldRetry(config, result) {
+			return result, err
+		}
+		if !sleepContext(ctx, config.RetryDelay) {
+			return result, err
+		}
+	}
+}
+
+// sendAuthorized envia a requisição com o token atual de -token-endpoint. Um
+// 401 indica um token expirado ou revogado antes do previsto: um novo é
+// obtido e a requisição é reenviada uma vez, sem contar como nova tentativa.
+func (r *requester) sendAuthorized(config Config, headers map[string]any, body RequestBody) (RequestResult, error) {
+	if r.tokens == nil {
+		return r.sendRequest(config, headers, body)
+	}
+
+	var result RequestResult
+	var err error
+	for range 2 {
+		config.BearerToken, err = r.tokens.current()
+		if err != nil {
+			return RequestResult{Start: time.Now(), Failure: FailureOther}, err
+		}
+		result, err = r.sendRequest(config, headers, body)
+		if result.StatusCode != http.StatusUnauthorized {
+			break
+		}
+		r.tokens.invalidate(config.BearerToken)
+	}
+	return result, err
+}
+
+// idempotentMethods são os métodos que podem ser repetidos sem -retry-all.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// isExpectedStatus informa se o status conta como sucesso: qualquer 2xx, ou
+// apenas os códigos de -expect-status ou as faixas de -success-codes quando
+// definidos.
+func isExpectedStatus(config Config, code int) bool {
+	switch {
+	case len(config.SuccessCodes) > 0:
+		return config.SuccessCodes.contains(code)
+	case len(config.ExpectStatus) > 0:
+		return slices.Contains(config.ExpectStatus, code)
+	default:
+		return code >= 200 && code < 300
+	}
+}
+
+// shouldRetry informa se vale repetir a tentativa: erros de conexão e
+// respostas 5xx, a menos que -expect-status ou -success-codes contem o status
+// como sucesso.
+func shouldRetry(config Config, result RequestResult) bool {
+	if result.Failure == FailureConnection {
+		return true
 	}
+	return result.StatusCode >= 500 && !isExpectedStatus(config, result.StatusCode)
+}
 
+func (r *requester) sendRequest(config Config, headers map[string]any, body RequestBody) (result RequestResult, err error) {
+	// Um *bytes.Reader permite que http.NewRequest preencha o
+	// Content-Length; com -force-body até um body vazio é enviado.
 	var bodyReader io.Reader
-	if len(body) > 0 {
-		bodyBytes, _ := json.Marshal(body)
-		bodyReader = io.NopCloser(io.Reader(bytes.NewReader(bodyBytes)))
+	switch {
+	case body.multipart != nil:
+		reader, err := body.multipart.open()
+		if err != nil {
+			return RequestResult{Start: time.Now(), Failure: FailureOther}, err
+		}
+		bodyReader = reader
+	case len(body.Data) > 0 || config.ForceBody:
+		bodyReader = bytes.NewReader(body.Data)
 	}
 
 	req, err := http.NewRequest(config.Method, config.URL, bodyReader)
 	if err != nil {
-		return 0, err
+		if closer, ok := bodyReader.(io.Closer); ok {
+			closer.Close()
+		}
+		return RequestResult{}, err
+	}
+	if body.multipart != nil {
+		req.ContentLength = body.multipart.size
+	}
+
+	// O User-Agent dos headers, se houver, tem prioridade sobre -user-agent.
+	if config.UserAgent != "" {
+		req.Header.Set("User-Agent", config.UserAgent)
 	}
 
 	// Adicionar headers
@@ -67,134 +748,1883 @@ func makeRequest(config Config, headers map[string]any, body map[string]any) (ti
 		req.Header.Set(key, fmt.Sprintf("%v", value))
 	}
 
-	if len(body) > 0 {
-		req.Header.Set("Content-Type", "application/json")
+	// A descompressão automática do transport está desligada para que os
+	// bytes recebidos possam ser medidos antes e depois da descompressão.
+	if req.Header.Get("Accept-Encoding") == "" && config.AcceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", config.AcceptEncoding)
+	}
+
+	if body.ContentType != "" {
+		req.Header.Set("Content-Type", body.ContentType)
+	}
+	if body.Encoding != "" {
+		req.Header.Set("Content-Encoding", body.Encoding)
+	}
+
+	// -host troca só o header Host; a conexão continua indo para o host da
+	// URL.
+	if config.Host != "" {
+		req.Host = config.Host
+	}
+
+	if config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.BearerToken)
+	}
+
+	if config.BasicUser != "" && config.BasicPass != "" {
+		req.SetBasicAuth(config.BasicUser, config.BasicPass)
+	}
+
+	// Com -auth negotiate as credenciais seguem no formato do basic até o
+	// Negotiator do transport, que as troca pelo handshake NTLM e não as
+	// envia como basic.
+	if config.Auth == "negotiate" {
+		req.SetBasicAuth(config.AuthUser, config.AuthPass)
+	}
+
+	// A última requisição permitida na conexão pede seu fechamento, e a
+	// seguinte abre uma nova.
+	if r.perConn > 0 {
+		r.connRequests++
+		if r.connRequests >= r.perConn {
+			req.Close = true
+			r.connRequests = 0
+		}
 	}
 
+	trace := &requestTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
 	start := time.Now()
-	resp, err := client.Do(req)
-	duration := time.Since(start)
+	resp, err := r.client.Do(req)
+	result = RequestResult{Start: start, Duration: time.Since(start), Method: req.Method, URL: req.URL.String(), BytesSent: body.size()}
+	trace.record(&result, start)
+	if r.logger != nil {
+		defer func() { r.logRequest(req, resp, result, err) }()
+	}
+	if r.capture != nil {
+		*r.capture = smokeCapture{req: req, reqBody: body.Data, resp: resp}
+	}
 
 	if err != nil {
-		return duration, err
+		result.Failure = classifyError(err)
+		return result, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return duration, fmt.Errorf("status code: %d", resp.StatusCode)
+	success := isExpectedStatus(config, resp.StatusCode)
+
+	// Os headers já chegaram, então as asserções sobre eles são verificadas
+	// antes do body; uma resposta que as viola não passa pelas do body.
+	var failedHeader *headerAssertion
+	var headerErr error
+	if success {
+		for i := range r.headerAssertions {
+			if headerErr = r.headerAssertions[i].check(resp.Header); headerErr != nil {
+				failedHeader = &r.headerAssertions[i]
+				break
+			}
+		}
+	}
+
+	// Consumir o body inteiro permite que a conexão volte ao pool de
+	// keep-alive; a duração passa a incluir o download da resposta. O body
+	// só é guardado quando as asserções precisam dele ou enquanto houver
+	// espaço para os dumps das falhas.
+	var captured *bytes.Buffer
+	sink := io.Discard
+	slot, dumping := 0, false
+	if !success || headerErr != nil {
+		slot, dumping = r.dumper.reserve()
+	}
+	if dumping || (success && headerErr == nil && r.assertion != nil) || r.capture != nil {
+		captured = &bytes.Buffer{}
+		sink = captured
+	}
+	result.BytesReceived, result.BytesDecoded, err = readResponseBody(sink, resp, int64(config.MaxBodySize))
+	if r.capture != nil {
+		r.capture.respBody = captured.Bytes()
+	}
+	result.Duration = time.Since(start)
+	trace.record(&result, start)
+	result.StatusCode = resp.StatusCode
+	result.Protocol = resp.Proto
+	result.Encoding = responseEncoding(resp)
+
+	var assertErr error
+	if err == nil && success && headerErr == nil && r.assertion != nil {
+		if assertErr = r.assertion.check(captured.Bytes()); assertErr != nil {
+			slot, dumping = r.dumper.reserve()
+		}
+	}
+	if dumping {
+		if dumpErr := r.dumper.dump(slot, req, body.Data, resp, captured.Bytes()); dumpErr != nil {
+			fmt.Fprintf(os.Stderr, "Aviso: não foi possível gravar o dump da falha: %v\n", dumpErr)
+		}
+	}
+	if err != nil {
+		result.Failure = classifyError(err)
+		return result, err
+	}
+
+	if !success {
+		result.Failure = FailureStatus
+		return result, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+
+	if headerErr != nil {
+		result.Failure = FailureHeader
+		result.FailedHeader = failedHeader.spec
+		return result, headerErr
+	}
+
+	if assertErr != nil {
+		result.Failure = FailureAssertion
+		return result, assertErr
 	}
 
-	return duration, nil
+	return result, nil
 }
 
-func runStressTest(config Config, headers map[string]any, body map[string]any) Results {
-	results := Results{}
-	var (
-		successCount int64
-		failedCount  int64
-		totalTime    int64
-		minDuration  = time.Duration(1<<63 - 1)
-		maxDuration  time.Duration
-		mu           sync.Mutex
-	)
+// mergeInlineHeaders soma ao mapa os headers de -header, no formato
+// "Key: Value". O valor pode conter ":"; só o primeiro separa o nome.
+func mergeInlineHeaders(headers map[string]any, inline []string) error {
+	for _, header := range inline {
+		key, value, ok := strings.Cut(header, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("header inválido %q, use \"Key: Value\"", header)
+		}
+		setHeader(headers, key, strings.TrimSpace(value))
+	}
+	return nil
+}
+
+// setHeader grava o header no mapa, trocando o que já existir com o mesmo
+// nome em outra capitalização, para que não sejam enviados os dois.
+func setHeader(headers map[string]any, key string, value any) {
+	for existing := range headers {
+		if http.CanonicalHeaderKey(existing) == http.CanonicalHeaderKey(key) {
+			delete(headers, existing)
+		}
+	}
+	headers[key] = value
+}
+
+// hasHeader informa se o header existe no mapa, ignorando maiúsculas e minúsculas.
+func hasHeader(headers map[string]any, name string) bool {
+	for key := range headers {
+		if http.CanonicalHeaderKey(key) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyError identifica o tipo de falha de transporte retornada pelo client.
+// Erros ao abrir a conexão, inclusive por -connect-timeout, contam como erro
+// de conexão e não se misturam aos timeouts de resposta.
+func classifyError(err error) FailureKind {
+	if errors.Is(err, errBodyTooLarge) {
+		return FailureTooLarge
+	}
+
+	// Sem descritores livres o socket nem chega a ser criado; a causa está
+	// no cliente, não no servidor.
+	if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) {
+		return FailureFileLimit
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return FailureDNS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return FailureConnection
+	}
+
+	// No HTTP/3 um servidor que não responde em QUIC só aparece como uma
+	// conexão sem atividade ou um handshake que não termina; é uma falha de
+	// conexão, não da requisição.
+	var idleErr *quic.IdleTimeoutError
+	var handshakeErr *quic.HandshakeTimeoutError
+	if errors.As(err, &idleErr) || errors.As(err, &handshakeErr) {
+		return FailureConnection
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return FailureTimeout
+	}
+
+	if errors.As(err, &opErr) {
+		return FailureConnection
+	}
+
+	return FailureOther
+}
+
+// infoOutput devolve onde as mensagens informativas devem ser escritas; na
+// saída JSON ou Prometheus o stdout fica reservado para o resultado e com -quiet elas são
+// descartadas.
+func infoOutput(config Config) io.Writer {
+	if config.Quiet {
+		return io.Discard
+	}
+	if config.Output != "text" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// runStressTest para de disparar novas requisições quando ctx é cancelado,
+// aguarda as que estão em andamento e devolve os resultados parciais. Com um
+// cenário, cada requisição usa um passo sorteado no lugar de URL, método,
+// headers e body.
+func runStressTest(ctx context.Context, requester *requester, config Config, headers map[string]any, body RequestBody, scenario *Scenario, data *dataset, urls []string, stream *eventStream) (Results, error) {
+	stats := newCollector(config)
+	tokensBefore := requester.tokens.fetches()
+	if scenario != nil {
+		for _, step := range scenario.Steps {
+			stats.stepOrder = append(stats.stepOrder, step.Name)
+		}
+	}
 
-	semaphore := make(chan struct{}, config.Concurrency)
 	var wg sync.WaitGroup
 
-	fmt.Printf("Iniciando stress test...\n")
-	fmt.Printf("URL: %s\n", config.URL)
-	fmt.Printf("Método: %s\n", config.Method)
-	fmt.Printf("Requisições: %d\n", config.Requests)
-	fmt.Printf("Concorrência: %d\n\n", config.Concurrency)
+	info := infoOutput(config)
+	fmt.Fprintf(info, "Iniciando stress test...\n")
+	if config.Name != "" {
+		fmt.Fprintf(info, "Execução: %s\n", config.Name)
+	}
+	if scenario != nil {
+		if scenario.Sequence {
+			fmt.Fprintf(info, "Cenário: %d passos, percorridos em ordem por cada worker\n", len(scenario.Steps))
+		} else {
+			fmt.Fprintf(info, "Cenário: %d passos\n", len(scenario.Steps))
+		}
+		for _, step := range scenario.Steps {
+			fmt.Fprintf(info, "  %s\n", step.describe(scenario.Sequence))
+		}
+	} else {
+		if len(urls) > 0 {
+			fmt.Fprintf(info, "URLs: %d de %s, em rodízio\n", len(urls), config.URLsFile)
+		} else {
+			fmt.Fprintf(info, "URL: %s\n", config.URL)
+		}
+		if len(config.Methods) > 0 {
+			fmt.Fprintf(info, "Métodos: %s\n", config.Methods.String())
+		} else {
+			fmt.Fprintf(info, "Método: %s\n", config.Method)
+		}
+	}
+	if config.Duration > 0 {
+		fmt.Fprintf(info, "Duração: %v\n", config.Duration)
+	} else if config.RequestsPerWorker > 0 {
+		fmt.Fprintf(info, "Requisições: %d (%d por worker)\n", config.Requests, config.RequestsPerWorker)
+	} else {
+		fmt.Fprintf(info, "Requisições: %d\n", config.Requests)
+	}
+	fmt.Fprintf(info, "Concorrência: %s\n", describeConcurrency(config))
+	if config.Proxy != "" {
+		fmt.Fprintf(info, "Proxy: %s\n", config.Proxy)
+	}
+	if config.UnixSocket != "" {
+		fmt.Fprintf(info, "Socket Unix: %s\n", config.UnixSocket)
+	}
+	if len(config.LocalAddrs) > 0 {
+		fmt.Fprintf(info, "Endereços de origem: %s, em rodízio por conexão\n", config.LocalAddrs.String())
+	}
+	if config.Host != "" {
+		fmt.Fprintf(info, "Host: %s\n", config.Host)
+	}
+	if config.Auth == "negotiate" {
+		fmt.Fprintf(info, "Autenticação: Negotiate (NTLM) como %s\n", config.AuthUser)
+	}
+	if config.Model == "open" {
+		fmt.Fprintf(info, "Modelo de carga: aberto, %v requisições por segundo agendadas (até %d simultâneas)\n", config.RPS, config.Concurrency)
+	} else if config.RPS > 0 {
+		fmt.Fprintf(info, "Limite de requisições por segundo: %v\n", config.RPS)
+	}
+	if config.DisableKeepAlive {
+		fmt.Fprintf(info, "Conexões: uma nova por requisição, sem keep-alive (latência inclui DNS, TCP e TLS)\n")
+	}
+	if config.HTTP3 {
+		fmt.Fprintf(info, "Protocolo: HTTP/3 (QUIC)\n")
+	} else if config.HTTP2Only {
+		fmt.Fprintf(info, "Protocolo: somente HTTP/2\n")
+	} else if !config.HTTP2 {
+		fmt.Fprintf(info, "Protocolo: somente HTTP/1.1\n")
+	}
+	if config.Insecure {
+		fmt.Fprintf(info, "Aviso: verificação de certificados TLS desativada (-insecure)\n")
+	}
+	if config.MaxDuration > 0 {
+		fmt.Fprintf(info, "Duração máxima: %v\n", config.MaxDuration)
+	}
+	if config.RampUp > 0 {
+		fmt.Fprintf(info, "Ramp-up: %v\n", config.RampUp)
+	}
+	if config.ThinkTime > 0 || config.ThinkJitter > 0 {
+		fmt.Fprintf(info, "Pausa entre requisições: %v (±%v)\n", config.ThinkTime, config.ThinkJitter)
+	}
+	if config.WarmupStabilize {
+		fmt.Fprintf(info, "Aquecimento: até estabilizar (tolerância %g%%, máximo %v)\n", config.StabilizeTolerance, config.MaxWarmupDuration)
+	} else if config.Warmup > 0 {
+		fmt.Fprintf(info, "Aquecimento: %d requisições\n", config.Warmup)
+	}
+	fmt.Fprintf(info, "Semente: %d\n", config.Seed)
+	if data != nil {
+		fmt.Fprintf(info, "Dados: %d linhas de %s\n", len(data.rows), config.DataFile)
+	}
+	fmt.Fprintln(info)
+
+	if config.WarmupStabilize {
+		batches, last, stable := warmUpUntilStable(ctx, requester, config, headers, body, data, urls)
+		if stable {
+			fmt.Fprintf(info, "Aquecimento estabilizado após %d lotes (latência média %v)\n\n", batches, last)
+		} else if ctx.Err() == nil {
+			fmt.Fprintf(info, "Aviso: a latência não estabilizou em %v (%d lotes); iniciando o teste assim mesmo\n\n", config.MaxWarmupDuration, batches)
+		}
+	} else if config.Warmup > 0 {
+		warmUp(ctx, requester, config, headers, body, data, urls)
+		fmt.Fprintf(info, "Aquecimento concluído\n\n")
+	}
+
+	// Com -rps cada disparo aguarda o próximo tick, mantendo uma taxa
+	// constante independente da velocidade de resposta do servidor. No
+	// modelo aberto o agendamento abaixo toma o lugar do limitador.
+	open := config.Model == "open"
+	var limiter <-chan time.Time
+	if config.RPS > 0 && !open {
+		ticker := time.NewTicker(max(time.Duration(float64(time.Second)/config.RPS), 1))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
 
 	startTime := time.Now()
+	stats.begin(startTime)
+	sampler := startResourceSampler(config, requester.conns)
 
-	for i := 0; i < config.Requests; i++ {
-		wg.Go(func() {
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+	// No modo por duração novas requisições são disparadas até o prazo
+	// expirar; as que já estão em andamento terminam normalmente. -max-duration
+	// impõe o mesmo prazo ao modo por número de requisições.
+	var (
+		dispatchCtx context.Context
+		cancel      context.CancelFunc
+	)
+	if config.Duration > 0 {
+		dispatchCtx, cancel = context.WithDeadline(ctx, startTime.Add(config.Duration))
+	} else if config.MaxDuration > 0 {
+		dispatchCtx, cancel = context.WithDeadline(ctx, startTime.Add(config.MaxDuration))
+	} else {
+		dispatchCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// abort para o disparo de novas requisições; as que estão em andamento
+	// terminam e entram nos resultados. Só o primeiro motivo é mantido.
+	var (
+		abortOnce   sync.Once
+		abortReason string
+	)
+	abort := func(reason string) {
+		abortOnce.Do(func() {
+			abortReason = reason
+			cancel()
+		})
+	}
 
-			duration, err := makeRequest(config, headers, body)
+	// Com -max-error-rate o disparo para assim que a taxa de erro passa do
+	// limite, depois de ao menos -min-samples requisições concluídas.
+	checkErrorRate := func() {
+		if config.MaxErrorRate <= 0 {
+			return
+		}
+		completed, success := stats.progress()
+		if completed < int64(config.MinSamples) {
+			return
+		}
+		if rate := float64(completed-success) / float64(completed) * 100; rate > config.MaxErrorRate {
+			abort(fmt.Sprintf("taxa de erro de %.2f%% acima do limite de %.2f%% após %d requisições", rate, config.MaxErrorRate, completed))
+		}
+	}
 
-			mu.Lock()
-			atomic.AddInt64(&totalTime, duration.Nanoseconds())
-			if duration < minDuration {
-				minDuration = duration
+	// Com -stop-on-first-failure a primeira falha encerra o teste, e seus
+	// detalhes viram o motivo exibido no resultado.
+	checkFailure := func(index int, result RequestResult, err error) {
+		if config.StopOnFailure && err != nil {
+			abort(describeFailure(index, result, err))
+		}
+	}
+
+	// O progresso só faz sentido em um terminal interativo e se misturaria
+	// às linhas de -verbose.
+	if !config.Quiet && !config.Verbose && !config.VeryVerbose && isTerminal(os.Stdout) {
+		total := config.Requests
+		if config.Duration > 0 {
+			total = 0
+		}
+		stopProgress := startProgress(stats, total)
+		defer stopProgress()
+	}
+
+	// Cada worker dispara uma requisição por vez e, com -think-time, pausa
+	// entre uma e outra. O contador de índices é compartilhado para que a
+	// numeração continue única entre os workers. Com -requests-per-worker
+	// cada worker também para ao completar a própria cota.
+	var next atomic.Int64
+	for w := range config.Concurrency {
+		wg.Go(func() {
+			requester := requester
+			if config.RequestsPerConn > 0 {
+				requester = requester.forWorker(config.RequestsPerConn)
+				defer requester.client.CloseIdleConnections()
 			}
-			if duration > maxDuration {
-				maxDuration = duration
+
+			if config.RampUp > 0 && config.Concurrency > 1 {
+				if !sleepContext(dispatchCtx, rampUpDelay(w, config.Concurrency, config.RampUp)) {
+					return
+				}
 			}
-			mu.Unlock()
 
-			if err != nil {
-				atomic.AddInt64(&failedCount, 1)
-			} else {
-				atomic.AddInt64(&successCount, 1)
+			for done := 0; config.RequestsPerWorker == 0 || done < config.RequestsPerWorker; done++ {
+				// A espera pelo limitador é o tempo em que o worker estava
+				// pronto mas -rps segurou o disparo.
+				var queueDelay time.Duration
+				if limiter != nil {
+					ready := time.Now()
+					select {
+					case <-limiter:
+					case <-dispatchCtx.Done():
+						return
+					}
+					queueDelay = time.Since(ready)
+				}
+				if dispatchCtx.Err() != nil {
+					return
+				}
+
+				i := int(next.Add(1) - 1)
+				if config.Duration == 0 && i >= config.Requests {
+					return
+				}
+
+				// No modelo aberto a requisição i chega no horário fixo
+				// i/-rps, responda o servidor rápido ou não. Se todos os
+				// workers estiverem ocupados ela espera na fila, e esse
+				// atraso é medido à parte da latência. Um worker livre que
+				// dorme até o horário não tem fila: o que o timer atrasar ao
+				// acordá-lo não conta.
+				if open {
+					scheduled := startTime.Add(time.Duration(float64(i) * float64(time.Second) / config.RPS))
+					queueDelay = max(time.Since(scheduled), 0)
+					if !sleepContext(dispatchCtx, time.Until(scheduled)) {
+						return
+					}
+				}
+
+				pause := thinkTime(config)
+				if scenario == nil {
+					reqConfig, reqBody := pickMethod(targetConfig(config, urls, i), body.variant(i))
+					result, err := requester.makeRequest(dispatchCtx, reqConfig, headers, reqBody, data.row(i))
+					result.QueueDelay = queueDelay
+					result.Worker = w
+					stats.add(i, result, err)
+					stream.send(w, i, result, err)
+					checkFailure(i, result, err)
+				} else {
+					step := scenario.next(done)
+					stepConfig := config
+					stepConfig.URL = step.URL
+					stepConfig.Method = step.Method
+					result, err := requester.makeRequest(dispatchCtx, stepConfig, step.headers, step.body, data.row(i))
+					result.Step = step.Name
+					result.QueueDelay = queueDelay
+					result.Worker = w
+					stats.add(i, result, err)
+					stream.send(w, i, result, err)
+					checkFailure(i, result, err)
+					pause = step.pause()
+				}
+				checkErrorRate()
+
+				// A pausa acontece depois de stats.add, então não entra na
+				// latência medida.
+				if !sleepContext(dispatchCtx, pause) {
+					return
+				}
 			}
 		})
 	}
 
 	wg.Wait()
+	results := stats.results()
 	results.TotalTime = time.Since(startTime)
-	results.TotalRequests = int64(config.Requests)
-	results.SuccessRequests = atomic.LoadInt64(&successCount)
-	results.FailedRequests = atomic.LoadInt64(&failedCount)
-	results.AverageDuration = time.Duration(totalTime / int64(config.Requests))
-	results.MinDuration = minDuration
-	results.MaxDuration = maxDuration
+	results.Interrupted = ctx.Err() != nil
+	results.AbortReason = abortReason
+	results.Seed = config.Seed
+	results.RunLimit = config.MaxDuration
+	results.Name = config.Name
+	results.TokenRefreshes = requester.tokens.fetches() - tokensBefore
+	results.Model = config.Model
+	results.RPSLimit = config.RPS
+	// No modo por duração, chegadas agendadas que ainda esperavam um worker
+	// quando o prazo acabou nunca foram disparadas: é a fila que sobrou.
+	if open && config.Duration > 0 && !results.Interrupted && abortReason == "" {
+		scheduled := int64(math.Ceil(config.Duration.Seconds() * config.RPS))
+		results.Unsent = max(scheduled-results.TotalRequests, 0)
+	}
+	sampler.finish(&results)
+	results.RunLimitReached = config.MaxDuration > 0 && dispatchCtx.Err() == context.DeadlineExceeded && results.TotalRequests < int64(config.Requests)
 
-	return results
+	// Todos os workers terminaram, então nenhum evento chega depois daqui.
+	return results, stream.close()
 }
 
-func printResults(results Results) {
-	fmt.Println("\n=== Resultados do Stress Test ===")
-	fmt.Printf("Total de requisições: %d\n", results.TotalRequests)
-	fmt.Printf("Requisições bem-sucedidas: %d\n", results.SuccessRequests)
-	fmt.Printf("Requisições falhadas: %d\n", results.FailedRequests)
-	fmt.Printf("Tempo total: %v\n", results.TotalTime)
-	fmt.Printf("Tempo médio por requisição: %v\n", results.AverageDuration)
-	fmt.Printf("Tempo mínimo: %v\n", results.MinDuration)
-	fmt.Printf("Tempo máximo: %v\n", results.MaxDuration)
-	fmt.Printf("Taxa de sucesso: %.2f%%\n", float64(results.SuccessRequests)/float64(results.TotalRequests)*100)
+// describeFailure resume uma requisição que falhou para -stop-on-first-failure.
+func describeFailure(index int, result RequestResult, err error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "primeira falha na requisição %d", index)
+	if result.Step != "" {
+		fmt.Fprintf(&b, " (passo %q)", result.Step)
+	}
+	if result.URL != "" {
+		fmt.Fprintf(&b, ": %s %s", result.Method, result.URL)
+	}
+	if result.StatusCode != 0 {
+		fmt.Fprintf(&b, " -> status %d", result.StatusCode)
+	}
+	fmt.Fprintf(&b, " após %v", result.Duration)
+	if result.Retries > 0 {
+		fmt.Fprintf(&b, " e %d novas tentativas", result.Retries)
+	}
+	fmt.Fprintf(&b, ": %v", err)
+	return b.String()
 }
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+// warmUp dispara config.Warmup requisições respeitando a concorrência e
+// descarta os resultados, para que caches frios não distorçam as métricas.
+func warmUp(ctx context.Context, requester *requester, config Config, headers map[string]any, body RequestBody, data *dataset, urls []string) {
+	warmUpBatch(ctx, requester, config, headers, body, data, urls, 0, config.Warmup)
 }
 
-func main() {
-	config := Config{}
-	config.URL = getEnvOrDefault("STRESS_URL", "http://localhost:8080/ping")
-	config.Method = getEnvOrDefault("STRESS_METHOD", "GET")
-	config.HeaderJSON = os.Getenv("STRESS_HEADERS_JSON")
-	config.BodyJSON = os.Getenv("STRESS_BODY_JSON")
+// warmUpBatch dispara as requisições de aquecimento de índices first até
+// first+n-1 e devolve a latência média delas, ou zero se nenhuma terminou.
+func warmUpBatch(ctx context.Context, requester *requester, config Config, headers map[string]any, body RequestBody, data *dataset, urls []string, first, n int) time.Duration {
+	semaphore := make(chan struct{}, config.Concurrency)
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total time.Duration
+		count int
+	)
 
-	requestsStr := getEnvOrDefault("STRESS_REQUESTS", "100")
-	config.Requests, _ = strconv.Atoi(requestsStr)
+	for i := first; i < first+n; i++ {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return average(total, count)
+		}
 
-	concurrencyStr := getEnvOrDefault("STRESS_CONCURRENCY", "10")
-	config.Concurrency, _ = strconv.Atoi(concurrencyStr)
+		wg.Go(func() {
+			defer func() { <-semaphore }()
+			reqConfig, reqBody := pickMethod(targetConfig(config, urls, i), body.variant(i))
+			result, _ := requester.makeRequest(ctx, reqConfig, headers, reqBody, data.row(i))
+			mu.Lock()
+			total += result.Duration
+			count++
+			mu.Unlock()
+		})
+	}
 
-	if config.URL == "" {
+	wg.Wait()
+	return average(total, count)
+}
+
+// warmUpUntilStable aquece em lotes até que a latência média de dois lotes
+// seguidos varie menos que -stabilize-tolerance, ou até -max-warmup-duration.
+// Devolve quantos lotes rodaram, a média do último e se a latência
+// estabilizou.
+func warmUpUntilStable(ctx context.Context, requester *requester, config Config, headers map[string]any, body RequestBody, data *dataset, urls []string) (batches int, last time.Duration, stable bool) {
+	ctx, cancel := context.WithTimeout(ctx, config.MaxWarmupDuration)
+	defer cancel()
+
+	size := config.Warmup
+	if size == 0 {
+		size = config.Concurrency * 10
+	}
+
+	var previous time.Duration
+	for ctx.Err() == nil {
+		avg := warmUpBatch(ctx, requester, config, headers, body, data, urls, batches*size, size)
+		// Um lote cortado pelo prazo não é comparável aos completos.
+		if ctx.Err() != nil {
+			break
+		}
+		batches++
+		if previous > 0 && avg > 0 {
+			change := math.Abs(float64(avg-previous)) / float64(previous) * 100
+			if change < config.StabilizeTolerance {
+				return batches, avg, true
+			}
+		}
+		previous, last = avg, avg
+	}
+	return batches, last, false
+}
+
+func average(total time.Duration, count int) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
+// targetConfig devolve a configuração da requisição de número index: com
+// -urls, a URL é a próxima da lista, em rodízio.
+func targetConfig(config Config, urls []string, index int) Config {
+	if len(urls) > 0 {
+		config.URL = urls[index%len(urls)]
+	}
+	return config
+}
+
+// pickMethod sorteia o método da requisição quando -methods foi usado. Os
+// métodos de leitura sorteados não enviam o body, a menos que -force-body
+// esteja ativo: o body configurado é o das escritas.
+func pickMethod(config Config, body RequestBody) (Config, RequestBody) {
+	if len(config.Methods) == 0 {
+		return config, body
+	}
+
+	config.Method = config.Methods.pick()
+	if !config.ForceBody && !methodHasBody(config.Method) {
+		body = RequestBody{}
+	}
+	return config, body
+}
+
+// methodHasBody informa se o método costuma levar body.
+func methodHasBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// rampUpDelay devolve quanto o worker de índice w aguarda antes de começar,
+// fazendo a concorrência efetiva crescer linearmente de 1 até o valor
+// configurado ao longo da janela.
+func rampUpDelay(w, concurrency int, window time.Duration) time.Duration {
+	return window * time.Duration(w) / time.Duration(concurrency-1)
+}
+
+// describeConcurrency mostra a concorrência efetiva e, quando ela foi
+// definida em relação às CPUs, de onde o número veio.
+func describeConcurrency(config Config) string {
+	if config.ConcurrencySpec == "" {
+		return fmt.Sprint(config.Concurrency)
+	}
+	return fmt.Sprintf("%d (%s com %d CPUs)", config.Concurrency, config.ConcurrencySpec, runtime.NumCPU())
+}
+
+// thinkTime sorteia a pausa entre duas requisições de um mesmo worker:
+// -think-time com uma variação uniforme de até ±-think-jitter.
+func thinkTime(config Config) time.Duration {
+	return jitteredPause(config.ThinkTime, config.ThinkJitter)
+}
+
+// jitteredPause devolve d com uma variação uniforme de até ±jitter, nunca
+// negativa.
+func jitteredPause(d, jitter time.Duration) time.Duration {
+	if jitter > 0 {
+		d += time.Duration(randInt64N(int64(2*jitter+1))) - jitter
+	}
+	return max(d, 0)
+}
+
+// sleepContext aguarda d ou o cancelamento de ctx, o que vier primeiro, e
+// informa se o contexto continua ativo.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func successRate(results Results) float64 {
+	if results.TotalRequests == 0 {
+		return 0
+	}
+	return float64(results.SuccessRequests) / float64(results.TotalRequests) * 100
+}
+
+// requestsPerSecond devolve a vazão média do teste.
+func requestsPerSecond(results Results) float64 {
+	if results.TotalTime <= 0 {
+		return 0
+	}
+	return float64(results.TotalRequests) / results.TotalTime.Seconds()
+}
+
+// formatBytes formata uma quantidade de bytes usando a maior unidade (KB,
+// MB, GB) que mantenha o valor acima de 1.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value := float64(n) / unit
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	i := 0
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.2f %s", value, suffixes[i])
+}
+
+func perSecond(n int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(n) / elapsed.Seconds())
+}
+
+// printPhases mostra em quanto tempo, em média, cada fase das requisições
+// levou. Fases como DNS e TLS só acontecem em conexões novas, por isso cada
+// linha indica em quantas requisições a fase foi medida.
+func printPhases(w io.Writer, phases []PhaseResults) {
+	if len(phases) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nFases da requisição (média):")
+	for i, phase := range phases {
+		if phase.Requests == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %-14s %12v (%d requisições)\n", phaseLabels[i]+":", phase.AverageDuration, phase.Requests)
+	}
+}
+
+// printHistogram desenha as faixas do histograma como barras, omitindo as
+// faixas vazias antes da primeira e depois da última com requisições.
+func printHistogram(w io.Writer, buckets []HistogramBucket) {
+	first, last := -1, -1
+	var largest int64
+	for i, bucket := range buckets {
+		if bucket.Count > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+			largest = max(largest, bucket.Count)
+		}
+	}
+	if first < 0 {
+		return
+	}
+
+	const width = 40
+	fmt.Fprintln(w, "\nHistograma de latência:")
+	for _, bucket := range buckets[first : last+1] {
+		label := "> " + buckets[len(buckets)-2].UpperBound.String()
+		if bucket.UpperBound > 0 {
+			label = "<= " + bucket.UpperBound.String()
+		}
+		bar := strings.Repeat("#", int(bucket.Count*width/largest))
+		fmt.Fprintf(w, "  %-10s |%-*s| %d\n", label, width, bar, bucket.Count)
+	}
+}
+
+func printResults(w io.Writer, results Results, colors palette) {
+	fmt.Fprintln(w, "\n"+colors.bold("=== Resultados do Stress Test ==="))
+	if results.Name != "" {
+		fmt.Fprintf(w, "Execução: %s\n", results.Name)
+	}
+	if results.Interrupted {
+		fmt.Fprintln(w, colors.yellow("Teste interrompido: resultados parciais"))
+	}
+	if results.AbortReason != "" {
+		fmt.Fprintln(w, colors.red("Teste abortado: "+results.AbortReason))
+	}
+	if results.RunLimitReached {
+		fmt.Fprintln(w, colors.yellow(fmt.Sprintf("Teste encerrado por -max-duration (%v) antes de completar as requisições: resultados parciais", results.RunLimit)))
+	} else if results.RunLimit > 0 && !results.Interrupted && results.AbortReason == "" {
+		fmt.Fprintf(w, "Teste encerrado ao completar as requisições, dentro de -max-duration (%v)\n", results.RunLimit)
+	}
+	fmt.Fprintf(w, "Total de requisições: %d\n", results.TotalRequests)
+	fmt.Fprintf(w, "Requisições bem-sucedidas: %s\n", colors.green(fmt.Sprint(results.SuccessRequests)))
+	fmt.Fprintf(w, "Requisições falhadas: %s\n", colors.failures(fmt.Sprint(results.FailedRequests), results.FailedRequests))
+	fmt.Fprintf(w, "Tempo total: %v\n", results.TotalTime)
+	fmt.Fprintf(w, "Tempo médio por requisição: %v (desvio padrão %v)\n", results.AverageDuration, results.StdDevDuration)
+	fmt.Fprintf(w, "Tempo mínimo: %v\n", results.MinDuration)
+	fmt.Fprintf(w, "Tempo máximo: %v\n", results.MaxDuration)
+	fmt.Fprintf(w, "P50: %v\n", results.P50Duration)
+	fmt.Fprintf(w, "P90: %v\n", results.P90Duration)
+	fmt.Fprintf(w, "P95: %v\n", results.P95Duration)
+	fmt.Fprintf(w, "P99: %v\n", results.P99Duration)
+	// A latência sozinha esconde a espera de quem chegou com o servidor
+	// ocupado; somada à fila ela é o tempo que um cliente real perceberia.
+	if results.Model == "open" {
+		fmt.Fprintf(w, "Atraso na fila: média %v, P95 %v, P99 %v, máximo %v\n", results.AverageQueueDelay, results.P95QueueDelay, results.P99QueueDelay, results.MaxQueueDelay)
+		fmt.Fprintf(w, "Latência com fila: P50 %v, P95 %v, P99 %v\n", results.P50WithQueue, results.P95WithQueue, results.P99WithQueue)
+		if results.Unsent > 0 {
+			fmt.Fprintln(w, colors.yellow(fmt.Sprintf("Chegadas agendadas não disparadas antes do fim: %d (a fila cresceu além da capacidade de -concurrency)", results.Unsent)))
+		}
+	} else if results.RPSLimit > 0 {
+		fmt.Fprintf(w, "Espera pelo limitador de -rps: média %v, P95 %v, P99 %v, máximo %v\n", results.AverageQueueDelay, results.P95QueueDelay, results.P99QueueDelay, results.MaxQueueDelay)
+		// Sem espera os workers estavam sempre atrasados para o próximo
+		// disparo: quem limita a vazão é o servidor, não o agendador.
+		if rps := requestsPerSecond(results); rps < 0.9*results.RPSLimit {
+			fmt.Fprintln(w, colors.yellow(fmt.Sprintf("Vazão de %.1f req/s abaixo de -rps %v: os workers raramente esperaram pelo limitador, então o gargalo é o servidor ou -concurrency", rps, results.RPSLimit)))
+		}
+	}
+	if results.MaxTTFB > 0 {
+		fmt.Fprintf(w, "TTFB médio: %v (mínimo %v, máximo %v)\n", results.AverageTTFB, results.MinTTFB, results.MaxTTFB)
+		fmt.Fprintf(w, "TTFB P50: %v, P90: %v, P95: %v, P99: %v\n", results.P50TTFB, results.P90TTFB, results.P95TTFB, results.P99TTFB)
+	}
+	rate := successRate(results)
+	fmt.Fprintf(w, "Taxa de sucesso: %s\n", colors.rate(fmt.Sprintf("%.2f%%", rate), rate))
+	if results.TotalRetries > 0 {
+		fmt.Fprintf(w, "Novas tentativas: %d\n", results.TotalRetries)
+	}
+	if results.TokenRefreshes > 0 {
+		fmt.Fprintf(w, "Tokens renovados em -token-endpoint: %d\n", results.TokenRefreshes)
+	}
+	if results.Redirects > 0 {
+		fmt.Fprintf(w, "Respostas 3xx (redirecionamentos): %d\n", results.Redirects)
+	}
+	fmt.Fprintf(w, "Dados recebidos: %s (%s/s)\n", formatBytes(results.BytesReceived), formatBytes(perSecond(results.BytesReceived, results.TotalTime)))
+	if results.BytesDecoded != results.BytesReceived && results.BytesReceived > 0 {
+		fmt.Fprintf(w, "Dados descomprimidos: %s (taxa de compressão %.1fx, economia de %.1f%% na rede)\n", formatBytes(results.BytesDecoded), float64(results.BytesDecoded)/float64(results.BytesReceived), (1-float64(results.BytesReceived)/float64(results.BytesDecoded))*100)
+	}
+	if results.MaxResponseSize > 0 {
+		fmt.Fprintf(w, "Tamanho das respostas: média %s (mínimo %s, máximo %s)\n", formatBytes(results.AverageResponseSize), formatBytes(results.MinResponseSize), formatBytes(results.MaxResponseSize))
+		fmt.Fprintf(w, "Tamanho P50: %s, P90: %s, P95: %s, P99: %s\n", formatBytes(results.P50ResponseSize), formatBytes(results.P90ResponseSize), formatBytes(results.P95ResponseSize), formatBytes(results.P99ResponseSize))
+	}
+	fmt.Fprintf(w, "Dados enviados: %s (%s/s)\n", formatBytes(results.BytesSent), formatBytes(perSecond(results.BytesSent, results.TotalTime)))
+	// Pouco reuso com keep-alive ativo costuma indicar um pool pequeno demais
+	// para a concorrência ou um servidor que fecha as conexões.
+	if conns := results.NewConns + results.ReusedConns; conns > 0 {
+		fmt.Fprintf(w, "Conexões: %d novas, %d reutilizadas (%.1f%% de reuso)\n", results.NewConns, results.ReusedConns, float64(results.ReusedConns)/float64(conns)*100)
+	}
+	// Os picos ajudam a dimensionar -concurrency frente aos limites do
+	// sistema, como o de arquivos abertos.
+	if results.PeakGoroutines > 0 {
+		fmt.Fprintf(w, "Picos de recursos: %d goroutines, %d conexões abertas, %s de heap\n", results.PeakGoroutines, results.PeakConns, formatBytes(int64(results.PeakHeap)))
+	}
+
+	if len(results.StatusCodes) > 0 {
+		codes := make([]int, 0, len(results.StatusCodes))
+		for code := range results.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+
+		// O tamanho médio por status destaca, por exemplo, páginas de erro
+		// muito maiores que as respostas de sucesso.
+		fmt.Fprintln(w, "\nStatus HTTP:")
+		for _, code := range codes {
+			fmt.Fprintf(w, "  %s: %d (média %s)\n", colors.status(fmt.Sprint(code), code), results.StatusCodes[code], formatBytes(results.StatusSizes[code]))
+		}
+	}
+
+	// Mostrar os protocolos negociados ajuda a perceber um servidor que
+	// rebaixa a conexão para HTTP/1.1 por falha no ALPN.
+	if len(results.Protocols) > 0 {
+		protocols := make([]string, 0, len(results.Protocols))
+		for protocol := range results.Protocols {
+			protocols = append(protocols, protocol)
+		}
+		sort.Strings(protocols)
+
+		fmt.Fprintln(w, "\nProtocolos:")
+		for _, protocol := range protocols {
+			fmt.Fprintf(w, "  %s: %d\n", protocol, results.Protocols[protocol])
+		}
+	}
+
+	// Quantas respostas vieram em cada codificação mostra se o servidor
+	// atendeu ao -accept-encoding.
+	if len(results.Encodings) > 0 {
+		encodings := make([]string, 0, len(results.Encodings))
+		for encoding := range results.Encodings {
+			encodings = append(encodings, encoding)
+		}
+		sort.Strings(encodings)
+
+		fmt.Fprintln(w, "\nCodificação das respostas:")
+		for _, encoding := range encodings {
+			fmt.Fprintf(w, "  %s: %d\n", encoding, results.Encodings[encoding])
+		}
+	}
+
+	printPhases(w, results.Phases)
+	printHistogram(w, results.Histogram)
+
+	if len(results.TimeSeries) > 0 {
+		fmt.Fprintln(w, "\nRequisições por intervalo:")
+		for _, point := range results.TimeSeries {
+			fmt.Fprintf(w, "  %8v: %d requisições (%.1f req/s), %d falhas, média %v\n",
+				point.Offset, point.Requests, point.RPS, point.Failed, point.AverageDuration)
+		}
+	}
+
+	if len(results.Steps) > 0 {
+		fmt.Fprintln(w, "\nPor passo do cenário:")
+		for _, step := range results.Steps {
+			fmt.Fprintf(w, "  %s: %d requisições, %d falhas, média %v, P50 %v, P95 %v, P99 %v\n",
+				step.Name, step.TotalRequests, step.FailedRequests, step.AverageDuration, step.P50Duration, step.P95Duration, step.P99Duration)
+		}
+	}
+
+	if len(results.Slowest) > 0 {
+		fmt.Fprintln(w, "\nRequisições mais lentas:")
+		for _, slow := range results.Slowest {
+			fmt.Fprintf(w, "  %v  #%d %s %s", slow.Duration, slow.Index, slow.Method, slow.URL)
+			if slow.Step != "" {
+				fmt.Fprintf(w, " [%s]", slow.Step)
+			}
+			if slow.StatusCode != 0 {
+				fmt.Fprintf(w, " -> %d", slow.StatusCode)
+			}
+			if slow.Error != "" {
+				fmt.Fprintf(w, " (%s)", slow.Error)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	if results.FailedRequests > 0 {
+		fmt.Fprintln(w, "\nFalhas por tipo:")
+		for _, kind := range failureKinds {
+			if count := results.Failures[kind]; count > 0 {
+				fmt.Fprintf(w, "  %s: %s\n", failureLabels[kind], colors.red(fmt.Sprint(count)))
+			}
+			if kind == FailureHeader {
+				for _, spec := range slices.Sorted(maps.Keys(results.HeaderFailures)) {
+					fmt.Fprintf(w, "    %q: %d\n", spec, results.HeaderFailures[spec])
+				}
+			}
+		}
+	}
+
+	if len(results.Workers) > 0 {
+		fmt.Fprintln(w, "\nPor worker:")
+		width := len(fmt.Sprint(len(results.Workers) - 1))
+		for _, worker := range results.Workers {
+			line := fmt.Sprintf("  worker %*d: %d requisições, %d falhas, média %v, máximo %v",
+				width, worker.Worker, worker.Requests, worker.FailedRequests, worker.AverageDuration, worker.MaxDuration)
+			if worker.Straggler {
+				line = colors.yellow(line + " (destoa dos demais)")
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if len(results.TopErrors) > 0 {
+		fmt.Fprintln(w, "\nErros mais frequentes:")
+		width := len(fmt.Sprint(results.TopErrors[0].Count))
+		for _, e := range results.TopErrors {
+			fmt.Fprintf(w, "  %s  %s\n", colors.red(fmt.Sprintf("%*d", width, e.Count)), e.Message)
+		}
+	}
+}
+
+// appendQuery acrescenta os parâmetros key=value à URL, mantendo os que já
+// existem na query string.
+func appendQuery(rawURL string, params []string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for _, param := range params {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok || key == "" {
+			return "", fmt.Errorf("parâmetro inválido %q, use key=value", param)
+		}
+		query.Add(key, value)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+func main() {
+	config := Config{}
+	flag.StringVar(&config.URL, "url", "http://localhost:8080/ping", "URL alvo do teste")
+	flag.StringVar(&config.Method, "method", "GET", "Método HTTP")
+	flag.StringVar(&config.HeaderFile, "headers", "", "Arquivo JSON com os headers da requisição")
+	flag.Var(&config.Headers, "header", "Header \"Key: Value\" somado aos de -headers, com prioridade sobre eles (pode ser repetido)")
+	flag.StringVar(&config.BodyFile, "body", "", "Arquivo JSON com o body da requisição")
+	flag.StringVar(&config.BodyDir, "body-dir", "", "Diretório cujos arquivos são usados em rodízio como body das requisições (.json como -body, os demais como -body-raw)")
+	flag.StringVar(&config.BodyRawFile, "body-raw", "", "Arquivo enviado sem alterações como body da requisição (form, XML, texto...)")
+	flag.StringVar(&config.ContentType, "content-type", "", "Content-Type do body (padrão: application/json para -body)")
+	flag.IntVar(&config.Requests, "requests", 100, "Número total de requisições")
+	flag.IntVar(&config.RequestsPerWorker, "requests-per-worker", 0, "Número de requisições de cada worker; o total passa a ser este valor vezes -concurrency")
+	config.Concurrency = 10
+	flag.Var(concurrencyValue{&config.Concurrency, &config.ConcurrencySpec}, "concurrency", "Número de requisições simultâneas; auto usa um worker por CPU e xN, N por CPU (ex: x4, x0.5)")
+	flag.DurationVar(&config.Duration, "duration", 0, "Duração do teste (ex: 30s); quando definida, tem prioridade sobre -requests")
+	flag.DurationVar(&config.Timeout, "timeout", 30*time.Second, "Timeout de cada requisição")
+	flag.DurationVar(&config.ConnectTimeout, "connect-timeout", 30*time.Second, "Tempo máximo para estabelecer cada conexão, dentro de -timeout (0 desativa)")
+	flag.Var(&config.MaxBodySize, "max-body-size", "Tamanho máximo lido de cada resposta, já descomprimida (ex: 10MB); acima dele a requisição falha como resposta grande demais (0 = sem limite)")
+	flag.StringVar(&config.Output, "output", "text", "Formato do resultado: text, json ou prometheus")
+	flag.StringVar(&config.CSVFile, "csv", "", "Arquivo CSV para exportar os dados de cada requisição")
+	flag.StringVar(&config.HTMLFile, "html", "", "Arquivo HTML para gravar um relatório com gráficos de latência e de requisições por segundo")
+	flag.Float64Var(&config.RPS, "rps", 0, "Limite de requisições por segundo (0 = sem limite)")
+	flag.StringVar(&config.Model, "model", "closed", "Modelo de carga: closed (cada worker espera a resposta anterior) ou open (requisições chegam na taxa de -rps, enfileirando se o servidor atrasar)")
+	flag.DurationVar(&config.RampUp, "rampup", 0, "Janela em que a concorrência cresce linearmente de 1 até -concurrency")
+	flag.IntVar(&config.MaxIdleConns, "max-idle-conns", 0, "Máximo de conexões ociosas mantidas no pool (0 = igual a -concurrency)")
+	flag.BoolVar(&config.DisableKeepAlive, "disable-keepalive", false, "Abre uma nova conexão a cada requisição, para medir conexões frias")
+	flag.BoolVar(&config.FreshConnections, "fresh-connections", false, "Usa uma conexão nova em cada requisição, medindo o custo de DNS, TCP e TLS (o mesmo que -disable-keepalive)")
+	flag.BoolVar(&config.Insecure, "insecure", false, "Não verifica o certificado TLS do servidor (aceita certificados autoassinados); "+
+		"INSEGURO: expõe o tráfego a ataques man-in-the-middle, use apenas contra serviços de teste confiáveis")
+	flag.StringVar(&config.BearerToken, "bearer", "", "Token enviado no header Authorization: Bearer <token>")
+	flag.StringVar(&config.TokenEndpoint, "token-endpoint", "", "URL do endpoint de token OAuth 2.0; o token obtido com -client-id/-client-secret é enviado como Bearer e renovado ao expirar ou em respostas 401")
+	flag.StringVar(&config.ClientID, "client-id", "", "Client ID usado em -token-endpoint")
+	flag.StringVar(&config.ClientSecret, "client-secret", "", "Client secret usado em -token-endpoint (prefira STRESS_CLIENT_SECRET)")
+	flag.StringVar(&config.TokenScope, "token-scope", "", "Escopos pedidos em -token-endpoint, separados por espaço")
+	flag.StringVar(&config.BasicUser, "basic-user", "", "Usuário para autenticação HTTP basic (requer -basic-pass)")
+	flag.StringVar(&config.BasicPass, "basic-pass", "", "Senha para autenticação HTTP basic (requer -basic-user)")
+	flag.StringVar(&config.Auth, "auth", "", "Esquema de autenticação com handshake: negotiate (NTLM, para IIS e serviços Windows)")
+	flag.StringVar(&config.AuthUser, "auth-user", "", "Usuário de -auth, no formato DOMINIO\\usuario ou usuario@dominio")
+	flag.StringVar(&config.AuthPass, "auth-pass", "", "Senha de -auth (prefira STRESS_AUTH_PASS)")
+	flag.Var(&config.Query, "query", "Parâmetro key=value adicionado à query string da URL (pode ser repetido)")
+	flag.IntVar(&config.Warmup, "warmup", 0, "Requisições de aquecimento disparadas antes do teste e excluídas das estatísticas")
+	flag.Var(&config.ConcurrencySweep, "concurrency-sweep", "Concorrências testadas em sequência, uma execução completa para cada (ex: 1,10,50,100)")
+	flag.DurationVar(&config.SweepCooldown, "sweep-cooldown", 0, "Pausa entre as execuções de -concurrency-sweep")
+	flag.BoolVar(&config.WarmupStabilize, "warmup-stabilize", false, "Aquece em lotes até a latência estabilizar; -warmup passa a ser o tamanho do lote (padrão: 10 x -concurrency)")
+	flag.Float64Var(&config.StabilizeTolerance, "stabilize-tolerance", 10, "Variação máxima (%) da latência média entre dois lotes para considerar o aquecimento estável")
+	flag.DurationVar(&config.MaxWarmupDuration, "max-warmup-duration", time.Minute, "Tempo máximo do aquecimento com -warmup-stabilize")
+	flag.IntVar(&config.Retries, "retries", 0, "Número de novas tentativas em erros de conexão e respostas 5xx")
+	flag.DurationVar(&config.RetryDelay, "retry-delay", 100*time.Millisecond, "Intervalo entre as tentativas")
+	flag.BoolVar(&config.RetryAll, "retry-all", false, "Repete também métodos não idempotentes, como POST e PATCH")
+	flag.BoolVar(&config.NoColor, "no-color", false, "Desativa as cores do resultado em texto, que só são usadas quando o stdout é um terminal")
+	flag.BoolVar(&config.Quiet, "quiet", false, "Exibe apenas o resultado final, sem o cabeçalho inicial, avisos e progresso")
+	flag.Float64Var(&config.FailUnder, "fail-under", 0, "Sai com código 1 se a taxa de sucesso (%) ficar abaixo deste valor")
+	flag.StringVar(&config.ConfigFile, "config", "", "Arquivo JSON com os valores das flags; a linha de comando e as variáveis STRESS_* têm prioridade sobre ele")
+	flag.StringVar(&config.ScenarioFile, "scenario", "", "Arquivo JSON com os passos (method, url, headers, body, weight) sorteados por peso a cada requisição")
+	flag.StringVar(&config.DumpDir, "dump-failures", "", "Diretório onde gravar requisição e resposta (status, headers e body) das respostas com status inesperado")
+	flag.IntVar(&config.DumpLimit, "dump-limit", 10, "Número máximo de falhas gravadas por -dump-failures")
+	flag.Var(&config.ExpectStatus, "expect-status", "Status considerados sucesso, separados por vírgula (ex: 200,204); padrão: qualquer 2xx")
+	flag.Var(&config.SuccessCodes, "success-codes", "Faixas de status consideradas sucesso, separadas por vírgula (ex: 200-299,304 ou 2xx,304); padrão: qualquer 2xx")
+	flag.BoolVar(&config.FollowRedirects, "follow-redirects", true, "Segue redirecionamentos; com false a resposta 3xx é medida e contabilizada como está")
+	flag.Var(&config.Buckets, "buckets", "Limites das faixas do histograma de latência, em ordem crescente (ex: 10ms,50ms,100ms,1s)")
+	flag.DurationVar(&config.Interval, "interval", time.Second, "Tamanho dos intervalos da série temporal de requisições, no mínimo 10ms (0 desativa)")
+	flag.StringVar(&config.UserAgent, "user-agent", "stress-test-tool/"+version, "User-Agent das requisições; um User-Agent definido em -headers tem prioridade")
+	flag.StringVar(&config.Proxy, "proxy", "", "Proxy para as requisições (http://, https:// ou socks5://); padrão: HTTP_PROXY/HTTPS_PROXY do ambiente")
+	flag.StringVar(&config.ClientCert, "client-cert", "", "Certificado PEM do cliente para TLS mútuo (requer -client-key)")
+	flag.StringVar(&config.ClientKey, "client-key", "", "Chave privada PEM do certificado do cliente (requer -client-cert)")
+	flag.StringVar(&config.CACert, "ca-cert", "", "Certificado PEM de uma CA adicional para validar o servidor")
+	flag.DurationVar(&config.ThinkTime, "think-time", 0, "Pausa de cada worker entre duas requisições consecutivas")
+	flag.DurationVar(&config.ThinkJitter, "think-jitter", 0, "Variação aleatória de até ± este valor aplicada a -think-time")
+	flag.BoolVar(&config.HTTP2, "http2", true, "Tenta negociar HTTP/2; com false todas as requisições usam HTTP/1.1")
+	flag.Var(&config.Files, "file", "Arquivo campo=@caminho enviado em um body multipart/form-data, junto com os campos de -form (pode ser repetido)")
+	flag.Var(&config.Form, "form", "Campo key=value de um body application/x-www-form-urlencoded (pode ser repetido)")
+	flag.IntVar(&config.TopSlow, "top-slow", 0, "Lista ao final as N requisições mais lentas")
+	flag.BoolVar(&config.PerWorkerStats, "per-worker-stats", false, "Exibe requisições e latência de cada worker, destacando os que destoam dos demais")
+	flag.IntVar(&config.TopErrors, "top-errors", 5, "Lista ao final as N mensagens de erro mais frequentes, agrupadas sem IPs e portas (0 desativa)")
+	flag.StringVar(&config.Host, "host", "", "Host enviado no header Host (e no SNI em HTTPS), independente do host de -url usado na conexão")
+	flag.Var(&config.LocalAddrs, "local-addr", "IP de origem das conexões; repetido, as conexões novas alternam entre os endereços")
+	flag.StringVar(&config.UnixSocket, "unix-socket", "", "Socket Unix para onde todas as conexões são feitas, mantendo o caminho e o host de -url")
+	flag.BoolVar(&config.StopOnFailure, "stop-on-first-failure", false, "Encerra o teste na primeira requisição que falhar, exibindo seus detalhes (útil para depuração, com baixa concorrência)")
+	flag.Float64Var(&config.MaxErrorRate, "max-error-rate", 0, "Aborta o teste quando a taxa de erro (%) passar deste valor (0 = desativado)")
+	flag.IntVar(&config.MinSamples, "min-samples", 100, "Requisições concluídas antes de -max-error-rate passar a valer")
+	flag.StringVar(&config.Compare, "compare", "", "Resultado JSON de uma execução anterior com o qual comparar o teste atual")
+	flag.Float64Var(&config.RegressionLimit, "regression-threshold", 10, "Piora máxima (%) aceita em relação a -compare antes de sair com código 1")
+	flag.DurationVar(&config.AssertP50, "assert-p50", 0, "Sai com código 1 se o P50 da latência passar deste valor (0 = desativado)")
+	flag.DurationVar(&config.AssertP90, "assert-p90", 0, "Sai com código 1 se o P90 da latência passar deste valor (0 = desativado)")
+	flag.DurationVar(&config.AssertP95, "assert-p95", 0, "Sai com código 1 se o P95 da latência passar deste valor (0 = desativado)")
+	flag.DurationVar(&config.AssertP99, "assert-p99", 0, "Sai com código 1 se o P99 da latência passar deste valor (0 = desativado)")
+	flag.StringVar(&config.AcceptEncoding, "accept-encoding", "gzip", "Valor do header Accept-Encoding (ex: br, \"gzip, br\"); gzip, deflate e br são descomprimidos para medir a economia")
+	flag.StringVar(&config.Name, "name", "", "Nome da execução (ex: baseline), incluído em todos os formatos de resultado")
+	flag.DurationVar(&config.MaxDuration, "max-duration", 0, "Tempo máximo do teste por número de requisições; ao atingi-lo o disparo para e o resultado é parcial")
+	flag.BoolVar(&config.AllowMissingEnv, "allow-missing-env", false, "Trata como vazias as variáveis ${VAR} não definidas nos arquivos de headers e body, em vez de falhar")
+	flag.StringVar(&config.StreamJSONL, "stream-jsonl", "", "Arquivo onde gravar cada requisição concluída, em JSON Lines, durante o teste")
+	flag.StringVar(&config.WaitFor, "wait-for", "", "URL consultada até responder 2xx antes de começar o teste, para serviços que ainda estão subindo")
+	flag.DurationVar(&config.WaitTimeout, "wait-timeout", time.Minute, "Tempo máximo esperando -wait-for ficar disponível antes de desistir")
+	flag.BoolVar(&config.Smoke, "smoke", false, "Envia uma única requisição e exibe a requisição, a resposta completa e os tempos, sem rodar o teste de carga")
+	flag.BoolVar(&config.DryRun, "dry-run", false, "Valida e exibe a configuração efetiva sem enviar nenhuma requisição")
+	flag.Var(&config.Methods, "methods", "Métodos sorteados por peso a cada requisição, no lugar de -method (ex: GET:80,POST:20)")
+	flag.Func("method-file", "Arquivo com um MÉTODO:peso por linha, como em -methods", config.Methods.loadFile)
+	flag.IntVar(&config.RequestsPerConn, "requests-per-conn", 0, "Máximo de requisições por conexão antes de abrir uma nova (0 = sem limite)")
+	flag.StringVar(&config.URLsFile, "urls", "", "Arquivo com uma URL por linha, usadas em rodízio no lugar de -url")
+	flag.BoolVar(&config.EnableCookies, "enable-cookies", false, "Guarda os cookies recebidos e os reenvia nas requisições seguintes")
+	flag.Var(&config.Cookies, "cookie", "Cookie key=value enviado desde a primeira requisição; ativa -enable-cookies (pode ser repetido)")
+	flag.StringVar(&config.AssertBodyContains, "assert-body-contains", "", "Texto que o body das respostas com status esperado deve conter")
+	flag.StringVar(&config.AssertBodyRegex, "assert-body-regex", "", "Expressão regular que o body das respostas com status esperado deve satisfazer")
+	flag.Var(&config.AssertHeaders, "assert-header", "Header que as respostas com status esperado devem ter: \"Key\", \"Key: valor\" ou \"Key: ~regex\" (pode ser repetido)")
+	flag.BoolVar(&config.Verbose, "verbose", false, "Registra no stderr método, URL, status e duração de cada requisição")
+	flag.BoolVar(&config.VeryVerbose, "vv", false, "Como -verbose, incluindo os headers da requisição e da resposta")
+	flag.BoolVar(&config.Compress, "compress", false, "Comprime o body com gzip e envia Content-Encoding: gzip")
+	flag.BoolVar(&config.ForceBody, "force-body", false, "Envia o body mesmo quando vazio, inclusive em GET; um -body vazio é enviado como {}")
+	flag.StringVar(&config.Report, "report", "", "Arquivo onde gravar também o resultado final, no formato de -output")
+	flag.Uint64Var(&config.Seed, "seed", 0, "Semente dos sorteios (cenário, -methods, -think-jitter); padrão: derivada do horário e exibida no início")
+	flag.StringVar(&config.DataFile, "data", "", "Arquivo CSV cujas linhas alimentam os templates da URL e do body, uma por requisição")
+	flag.BoolVar(&config.HTTP3, "http3", false, "Usa HTTP/3 sobre QUIC (UDP); exige URLs https:// e um servidor com HTTP/3")
+	flag.BoolVar(&config.HTTP2Only, "http2-only", false, "Usa apenas HTTP/2, falhando em vez de recorrer ao HTTP/1.1")
+	flag.Parse()
+
+	// Ordem de prioridade: flags, variáveis de ambiente, arquivo de
+	// configuração e, por fim, os valores padrão.
+	if err := applyEnvDefaults(); err != nil {
+		fmt.Printf("Erro: %v\n", err)
+		os.Exit(1)
+	}
+
+	if config.ConfigFile != "" {
+		if err := applyConfigFile(config.ConfigFile); err != nil {
+			fmt.Printf("Erro: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	config.HeaderJSON = os.Getenv("STRESS_HEADERS_JSON")
+	config.BodyJSON = os.Getenv("STRESS_BODY_JSON")
+
+	if config.URL == "" {
 		fmt.Println("Erro: STRESS_URL é obrigatório")
 		os.Exit(1)
 	}
 
-	headers, err := loadJSON(config.HeaderJSON)
+	var urls []string
+	if config.URLsFile != "" {
+		if config.ScenarioFile != "" {
+			fmt.Println("Erro: use -urls ou -scenario, não ambos")
+			os.Exit(1)
+		}
+		var err error
+		urls, err = loadURLs(config.URLsFile)
+		if err != nil {
+			fmt.Printf("Erro ao carregar -urls: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(config.Query) > 0 {
+		targets := []*string{&config.URL}
+		for i := range urls {
+			targets = append(targets, &urls[i])
+		}
+		for _, target := range targets {
+			if strings.Contains(*target, "{{") {
+				fmt.Println("Erro: -query não pode ser usado com uma URL com template; inclua os parâmetros na própria URL")
+				os.Exit(1)
+			}
+			var err error
+			*target, err = appendQuery(*target, config.Query)
+			if err != nil {
+				fmt.Printf("Erro em -query: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if config.RequestsPerWorker < 0 {
+		fmt.Println("Erro: -requests-per-worker não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.RequestsPerWorker > 0 && isSet("requests") {
+		fmt.Println("Erro: -requests e -requests-per-worker não podem ser usados juntos")
+		os.Exit(1)
+	}
+
+	if len(config.SuccessCodes) > 0 && len(config.ExpectStatus) > 0 {
+		fmt.Println("Erro: -success-codes e -expect-status não podem ser usados juntos")
+		os.Exit(1)
+	}
+
+	if config.AssertP50 < 0 || config.AssertP90 < 0 || config.AssertP95 < 0 || config.AssertP99 < 0 {
+		fmt.Println("Erro: os limites de -assert-p50, -assert-p90, -assert-p95 e -assert-p99 não podem ser negativos")
+		os.Exit(1)
+	}
+
+	if config.MaxDuration < 0 {
+		fmt.Println("Erro: -max-duration não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.MaxDuration > 0 && config.Duration > 0 {
+		fmt.Println("Erro: -max-duration limita o modo por número de requisições e não pode ser usado com -duration")
+		os.Exit(1)
+	}
+
+	if config.RequestsPerWorker > 0 && config.Duration > 0 {
+		fmt.Println("Erro: -requests-per-worker não pode ser usado com -duration")
+		os.Exit(1)
+	}
+
+	if config.Duration <= 0 && config.Requests <= 0 {
+		fmt.Println("Erro: -requests deve ser maior que zero")
+		os.Exit(1)
+	}
+
+	if config.Concurrency <= 0 {
+		fmt.Println("Erro: -concurrency deve ser maior que zero")
+		os.Exit(1)
+	}
+
+	if len(config.ConcurrencySweep) > 0 {
+		switch {
+		case config.Output == "prometheus":
+			fmt.Println("Erro: -concurrency-sweep não suporta -output prometheus")
+			os.Exit(1)
+		case config.CSVFile != "" || config.HTMLFile != "" || config.StreamJSONL != "" || config.Compare != "":
+			fmt.Println("Erro: -concurrency-sweep não pode ser usado com -csv, -html, -stream-jsonl ou -compare")
+			os.Exit(1)
+		case config.FailUnder > 0 || config.AssertP50 > 0 || config.AssertP90 > 0 || config.AssertP95 > 0 || config.AssertP99 > 0:
+			fmt.Println("Erro: -concurrency-sweep não pode ser usado com -fail-under nem com -assert-p50/-p90/-p95/-p99")
+			os.Exit(1)
+		case config.SweepCooldown < 0:
+			fmt.Println("Erro: -sweep-cooldown não pode ser negativo")
+			os.Exit(1)
+		}
+		// O pool de conexões é dimensionado pelo maior nível.
+		config.Concurrency = slices.Max(config.ConcurrencySweep)
+	}
+
+	// Com -requests-per-worker o total é derivado, e o restante do teste
+	// (cabeçalho, progresso e resultados) continua trabalhando com ele.
+	if config.RequestsPerWorker > 0 {
+		config.Requests = config.RequestsPerWorker * config.Concurrency
+	}
+
+	if config.Timeout < 0 {
+		fmt.Println("Erro: -timeout não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.ConnectTimeout < 0 {
+		fmt.Println("Erro: -connect-timeout não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.RegressionLimit < 0 {
+		fmt.Println("Erro: -regression-threshold não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.FailUnder < 0 || config.FailUnder > 100 {
+		fmt.Println("Erro: -fail-under deve estar entre 0 e 100")
+		os.Exit(1)
+	}
+
+	if config.Interval < 0 || (config.Interval > 0 && config.Interval < minInterval) {
+		fmt.Printf("Erro: -interval deve ser 0 ou pelo menos %v\n", minInterval)
+		os.Exit(1)
+	}
+
+	if config.Retries < 0 || config.RetryDelay < 0 {
+		fmt.Println("Erro: -retries e -retry-delay não podem ser negativos")
+		os.Exit(1)
+	}
+
+	if config.Warmup < 0 {
+		fmt.Println("Erro: -warmup não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.WarmupStabilize && (config.StabilizeTolerance <= 0 || config.MaxWarmupDuration <= 0) {
+		fmt.Println("Erro: -stabilize-tolerance e -max-warmup-duration devem ser maiores que zero")
+		os.Exit(1)
+	}
+
+	if config.RampUp < 0 {
+		fmt.Println("Erro: -rampup não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.HTTP2Only && !config.HTTP2 {
+		fmt.Println("Erro: -http2-only não pode ser usado com -http2=false")
+		os.Exit(1)
+	}
+
+	if config.HTTP3 {
+		switch {
+		case config.HTTP2Only:
+			fmt.Println("Erro: use -http3 ou -http2-only, não ambos")
+			os.Exit(1)
+		case config.UnixSocket != "" || config.Proxy != "" || len(config.LocalAddrs) > 0:
+			fmt.Println("Erro: -http3 não pode ser usado com -unix-socket, -proxy ou -local-addr")
+			os.Exit(1)
+		case config.DisableKeepAlive || config.FreshConnections || config.RequestsPerConn > 0:
+			fmt.Println("Erro: -http3 não pode ser usado com -disable-keepalive, -fresh-connections ou -requests-per-conn")
+			os.Exit(1)
+		}
+	}
+
+	if len(config.Methods) > 0 {
+		if config.ScenarioFile != "" {
+			fmt.Println("Erro: use -methods ou -scenario, não ambos")
+			os.Exit(1)
+		}
+		if isSet("method") {
+			fmt.Println("Erro: use -method ou -methods, não ambos")
+			os.Exit(1)
+		}
+	}
+
+	if config.UnixSocket != "" && config.Proxy != "" {
+		fmt.Println("Erro: use -unix-socket ou -proxy, não ambos")
+		os.Exit(1)
+	}
+
+	if len(config.LocalAddrs) > 0 {
+		if config.UnixSocket != "" {
+			fmt.Println("Erro: use -unix-socket ou -local-addr, não ambos")
+			os.Exit(1)
+		}
+		if err := checkLocalAddrs(config.LocalAddrs); err != nil {
+			fmt.Printf("Erro: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.Smoke && config.DryRun {
+		fmt.Println("Erro: use -smoke ou -dry-run, não ambos")
+		os.Exit(1)
+	}
+
+	if config.WaitFor != "" {
+		if parsed, err := url.Parse(config.WaitFor); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			fmt.Printf("Erro: -wait-for deve ser uma URL http:// ou https://, recebido %q\n", config.WaitFor)
+			os.Exit(1)
+		}
+		if config.WaitTimeout <= 0 {
+			fmt.Println("Erro: -wait-timeout deve ser maior que zero")
+			os.Exit(1)
+		}
+	}
+
+	if config.MaxErrorRate < 0 || config.MaxErrorRate > 100 {
+		fmt.Println("Erro: -max-error-rate deve estar entre 0 e 100")
+		os.Exit(1)
+	}
+
+	if config.MinSamples < 1 {
+		fmt.Println("Erro: -min-samples deve ser maior que zero")
+		os.Exit(1)
+	}
+
+	if config.TopSlow < 0 {
+		fmt.Println("Erro: -top-slow não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.TopErrors < 0 {
+		fmt.Println("Erro: -top-errors não pode ser negativo")
+		os.Exit(1)
+	}
+
+	if config.RequestsPerConn < 0 {
+		fmt.Println("Erro: -requests-per-conn não pode ser negativo")
+		os.Exit(1)
+	}
+
+	// -fresh-connections é o nome explícito para o modo sem keep-alive; as
+	// duas flags levam ao mesmo transport.
+	if config.FreshConnections {
+		config.DisableKeepAlive = true
+	}
+
+	if config.DisableKeepAlive && config.RequestsPerConn > 0 {
+		fmt.Println("Erro: -requests-per-conn não pode ser usado com -fresh-connections ou -disable-keepalive")
+		os.Exit(1)
+	}
+
+	if config.ThinkTime < 0 || config.ThinkJitter < 0 {
+		fmt.Println("Erro: -think-time e -think-jitter não podem ser negativos")
+		os.Exit(1)
+	}
+
+	if config.RPS < 0 {
+		fmt.Println("Erro: -rps não pode ser negativo")
+		os.Exit(1)
+	}
+
+	switch config.Model {
+	case "closed":
+	case "open":
+		switch {
+		case config.RPS == 0:
+			fmt.Println("Erro: -model open exige -rps, a taxa de chegada das requisições")
+			os.Exit(1)
+		case config.RampUp > 0 || config.ThinkTime > 0 || config.RequestsPerWorker > 0:
+			fmt.Println("Erro: -model open não pode ser usado com -rampup, -think-time ou -requests-per-worker")
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("Erro: -model inválido %q, use closed ou open\n", config.Model)
+		os.Exit(1)
+	}
+
+	if (config.BasicUser == "") != (config.BasicPass == "") {
+		fmt.Println("Erro: -basic-user e -basic-pass devem ser usados juntos")
+		os.Exit(1)
+	}
+
+	if config.BasicUser != "" && config.BearerToken != "" {
+		fmt.Println("Erro: use -bearer ou -basic-user/-basic-pass, não ambos")
+		os.Exit(1)
+	}
+
+	switch config.Auth {
+	case "":
+		if config.AuthUser != "" || config.AuthPass != "" {
+			fmt.Println("Erro: -auth-user e -auth-pass só valem com -auth negotiate")
+			os.Exit(1)
+		}
+	case "negotiate":
+		switch {
+		case config.AuthUser == "" || config.AuthPass == "":
+			fmt.Println("Erro: -auth negotiate exige -auth-user e -auth-pass; Kerberos pelo cache de tickets não é suportado")
+			os.Exit(1)
+		case config.BearerToken != "" || config.BasicUser != "" || config.TokenEndpoint != "":
+			fmt.Println("Erro: -auth negotiate não pode ser usado com -bearer, -basic-user ou -token-endpoint")
+			os.Exit(1)
+		case config.HTTP3 || config.HTTP2Only:
+			fmt.Println("Erro: -auth negotiate exige HTTP/1.1 e não pode ser usado com -http3 ou -http2-only")
+			os.Exit(1)
+		case config.RequestsPerConn > 0:
+			fmt.Println("Erro: -auth negotiate não pode ser usado com -requests-per-conn")
+			os.Exit(1)
+		}
+		// O NTLM autentica a conexão, não a requisição, e não funciona
+		// sobre HTTP/2.
+		config.HTTP2 = false
+	default:
+		fmt.Printf("Erro: -auth inválido %q, use negotiate\n", config.Auth)
+		os.Exit(1)
+	}
+
+	if config.TokenEndpoint != "" {
+		switch {
+		case config.BearerToken != "" || config.BasicUser != "":
+			fmt.Println("Erro: -token-endpoint não pode ser usado com -bearer ou -basic-user/-basic-pass")
+			os.Exit(1)
+		case config.ClientID == "" || config.ClientSecret == "":
+			fmt.Println("Erro: -token-endpoint exige -client-id e -client-secret")
+			os.Exit(1)
+		}
+	} else if config.ClientID != "" || config.ClientSecret != "" || config.TokenScope != "" {
+		fmt.Println("Erro: -client-id, -client-secret e -token-scope só valem com -token-endpoint")
+		os.Exit(1)
+	}
+
+	if config.BodyRawFile != "" && config.BodyFile != "" {
+		fmt.Println("Erro: use -body ou -body-raw, não ambos")
+		os.Exit(1)
+	}
+
+	if config.HeaderFile == "-" && (config.BodyFile == "-" || config.BodyRawFile == "-") {
+		fmt.Println("Erro: apenas um de -headers e -body/-body-raw pode ser lido do stdin (-)")
+		os.Exit(1)
+	}
+
+	if len(config.Files) > 0 && (config.BodyFile != "" || config.BodyRawFile != "" || config.BodyJSON != "") {
+		fmt.Println("Erro: -file não pode ser usado com -body, -body-raw ou STRESS_BODY_JSON")
+		os.Exit(1)
+	}
+
+	if len(config.Form) > 0 && (config.BodyFile != "" || config.BodyRawFile != "" || config.BodyJSON != "") {
+		fmt.Println("Erro: -form não pode ser usado com -body, -body-raw ou STRESS_BODY_JSON")
+		os.Exit(1)
+	}
+
+	if config.BodyDir != "" {
+		switch {
+		case config.BodyFile != "" || config.BodyRawFile != "" || config.BodyJSON != "" || len(config.Form) > 0 || len(config.Files) > 0:
+			fmt.Println("Erro: -body-dir não pode ser usado com -body, -body-raw, -form, -file ou STRESS_BODY_JSON")
+			os.Exit(1)
+		case config.ScenarioFile != "":
+			fmt.Println("Erro: -body-dir não pode ser usado com -scenario; defina o body de cada passo no cenário")
+			os.Exit(1)
+		}
+	}
+
+	if config.DumpDir != "" {
+		if config.DumpLimit < 0 {
+			fmt.Println("Erro: -dump-limit não pode ser negativo")
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(config.DumpDir, 0o755); err != nil {
+			fmt.Printf("Erro ao criar o diretório de dumps: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.Output != "text" && config.Output != "json" && config.Output != "prometheus" {
+		fmt.Println("Erro: -output deve ser text, json ou prometheus")
+		os.Exit(1)
+	}
+
+	// Sem -seed a semente vem do horário; ela é exibida no início para que
+	// uma execução instável possa ser repetida com o mesmo valor.
+	if !isSet("seed") {
+		config.Seed = uint64(time.Now().UnixNano())
+	}
+	seedRandom(config.Seed)
+
+	if config.Duration > 0 && isSet("requests") {
+		fmt.Fprintln(infoOutput(config), "Aviso: -requests e -duration foram definidos; -requests será ignorado e o teste rodará por duração")
+	}
+
+	headers, err := loadJSONOrFile(config.HeaderJSON, config.HeaderFile, config.AllowMissingEnv)
 	if err != nil {
 		fmt.Printf("Erro ao carregar headers: %v\n", err)
 		os.Exit(1)
 	}
+	if headers == nil {
+		headers = map[string]any{}
+	}
+	if err := mergeInlineHeaders(headers, config.Headers); err != nil {
+		fmt.Printf("Erro em -header: %v\n", err)
+		os.Exit(1)
+	}
 
-	body, err := loadJSON(config.BodyJSON)
+	// O net/http ignora um header Host; o valor só chega ao servidor por
+	// req.Host.
+	if hasHeader(headers, "Host") {
+		fmt.Fprintln(infoOutput(config), "Aviso: o header Host dos headers é ignorado; use -host")
+	}
+	if config.BearerToken != "" && hasHeader(headers, "Authorization") {
+		fmt.Fprintln(infoOutput(config), "Aviso: o header Authorization dos headers será substituído por -bearer")
+	}
+	if config.TokenEndpoint != "" && hasHeader(headers, "Authorization") {
+		fmt.Fprintln(infoOutput(config), "Aviso: o header Authorization dos headers será substituído pelo token de -token-endpoint")
+	}
+
+	body, err := loadBody(config)
 	if err != nil {
 		fmt.Printf("Erro ao carregar body: %v\n", err)
 		os.Exit(1)
 	}
 
-	results := runStressTest(config, headers, body)
-	printResults(results)
+	var scenario *Scenario
+	if config.ScenarioFile != "" {
+		scenario, err = loadScenario(config.ScenarioFile, config, headers)
+		if err != nil {
+			fmt.Printf("Erro ao carregar cenário: %v\n", err)
+			os.Exit(1)
+		}
+		// No modelo aberto as chegadas não pertencem a um usuário, então não
+		// há quem pause entre passos nem quem os percorra em ordem.
+		if config.Model == "open" && (scenario.Sequence || scenario.hasThinkTime()) {
+			fmt.Println("Erro: -model open não pode ser usado com cenários em sequência nem com thinkTime nos passos")
+			os.Exit(1)
+		}
+	}
+
+	// Bodies sem template são comprimidos uma única vez, antes do teste.
+	if config.Compress {
+		if body.template == nil {
+			if body, err = compressBody(body); err != nil {
+				fmt.Printf("Erro ao comprimir o body: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		for i := range body.variants {
+			variant := &body.variants[i]
+			if variant.template == nil {
+				if *variant, err = compressBody(*variant); err != nil {
+					fmt.Printf("Erro ao comprimir os bodies de -body-dir: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+		if scenario != nil {
+			for i := range scenario.Steps {
+				step := &scenario.Steps[i]
+				if step.body.template == nil {
+					if step.body, err = compressBody(step.body); err != nil {
+						fmt.Printf("Erro ao comprimir o body do passo %q: %v\n", step.Name, err)
+						os.Exit(1)
+					}
+				}
+			}
+		}
+	}
+
+	// O relatório é criado antes do teste para que um caminho inválido seja
+	// reportado antes de disparar qualquer requisição.
+	var report *os.File
+	if config.Report != "" && !config.DryRun {
+		report, err = os.Create(config.Report)
+		if err != nil {
+			fmt.Printf("Erro ao criar o relatório: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var stream *eventStream
+	if config.StreamJSONL != "" && !config.DryRun {
+		stream, err = newEventStream(config.StreamJSONL)
+		if err != nil {
+			fmt.Printf("Erro ao criar %s: %v\n", config.StreamJSONL, err)
+			os.Exit(1)
+		}
+	}
+
+	// A referência também é lida antes, para não descobrir um arquivo
+	// inválido só depois de todo o teste.
+	var baseline Results
+	if config.Compare != "" {
+		baseline, err = loadBaseline(config.Compare)
+		if err != nil {
+			fmt.Printf("Erro ao carregar a referência: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var data *dataset
+	if config.DataFile != "" {
+		data, err = loadDataset(config.DataFile)
+		if err != nil {
+			fmt.Printf("Erro ao carregar -data: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// O primeiro Ctrl+C interrompe o disparo e exibe os resultados parciais;
+	// a partir daí o comportamento padrão do sinal é restaurado.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	requester, err := newRequester(config)
+	if err != nil {
+		fmt.Printf("Erro ao configurar as requisições: %v\n", err)
+		os.Exit(1)
+	}
+	defer requester.client.CloseIdleConnections()
+
+	targets := []string{config.URL}
+	switch {
+	case scenario != nil:
+		targets = targets[:0]
+		for _, step := range scenario.Steps {
+			targets = append(targets, step.URL)
+		}
+	case len(urls) > 0:
+		targets = urls
+	}
+	for _, rawURL := range targets {
+		if config.HTTP3 && !strings.HasPrefix(rawURL, "https://") {
+			fmt.Printf("Erro: -http3 exige URLs https://, recebido %q\n", rawURL)
+			os.Exit(1)
+		}
+		if err := requester.addURLTemplate(rawURL); err != nil {
+			fmt.Printf("Erro: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if len(config.Cookies) > 0 {
+		if err := seedCookies(requester.client.Jar, targets, config.Cookies); err != nil {
+			fmt.Printf("Erro em -cookie: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := checkTemplates(requester, config, body, scenario, data, urls); err != nil {
+		fmt.Printf("Erro nos templates: %v\n", err)
+		os.Exit(1)
+	}
+
+	if config.DryRun {
+		printDryRun(os.Stdout, config, headers, body, scenario, data, urls)
+		return
+	}
+
+	if config.WaitFor != "" {
+		info := infoOutput(config)
+		fmt.Fprintf(info, "Aguardando %s responder (até %v)...\n", config.WaitFor, config.WaitTimeout)
+		waited, attempts, err := waitForReady(ctx, config)
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "Interrompido antes do início do teste")
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Erro: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(info, "Serviço disponível após %v (%d tentativas)\n\n", waited.Round(time.Millisecond), attempts)
+	}
+
+	// O primeiro token é obtido antes do teste, para que credenciais erradas
+	// apareçam como um erro só e não como uma falha por requisição.
+	if requester.tokens != nil {
+		if _, err := requester.tokens.current(); err != nil {
+			fmt.Printf("Erro em -token-endpoint: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// -smoke vem antes dos modos de carga e ignora -requests, -concurrency,
+	// o aquecimento e a varredura.
+	if config.Smoke {
+		if err := runSmoke(ctx, os.Stdout, requester, config, headers, body, scenario, data, urls); err != nil {
+			fmt.Printf("\nErro: a requisição falhou: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("\nRequisição bem-sucedida")
+		return
+	}
+
+	if len(config.ConcurrencySweep) > 0 {
+		levels := runSweep(ctx, requester, config, headers, body, scenario, data, urls)
+		colors := newPalette(config)
+
+		var output, plain bytes.Buffer
+		if config.Output == "json" {
+			if err := printSweepJSON(&output, config.Name, levels); err != nil {
+				fmt.Fprintf(os.Stderr, "Erro ao gerar JSON: %v\n", err)
+				os.Exit(1)
+			}
+			plain = output
+		} else {
+			printSweep(&output, levels, colors)
+			printSweep(&plain, levels, palette{})
+		}
+		os.Stdout.Write(output.Bytes())
+
+		if report != nil {
+			_, err := report.Write(plain.Bytes())
+			if closeErr := report.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro ao gravar o relatório: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		for _, level := range levels {
+			if level.Results.AbortReason != "" {
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	results, streamErr := runStressTest(ctx, requester, config, headers, body, scenario, data, urls, stream)
+	colors := newPalette(config)
+
+	// O resultado é montado em memória para que o mesmo conteúdo vá para o
+	// stdout e, com -report, para o arquivo.
+	var output bytes.Buffer
+	switch config.Output {
+	case "json":
+		if err := printJSONResults(&output, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao gerar JSON: %v\n", err)
+			os.Exit(1)
+		}
+	case "prometheus":
+		printPrometheusResults(&output, results)
+	default:
+		printResults(&output, results, colors)
+	}
+	os.Stdout.Write(output.Bytes())
+
+	if report != nil {
+		// As cores são só para o terminal: o relatório recebe o texto puro.
+		content := output.Bytes()
+		if colors.enabled && config.Output == "text" {
+			var plain bytes.Buffer
+			printResults(&plain, results, palette{})
+			content = plain.Bytes()
+		}
+		_, err := report.Write(content)
+		if closeErr := report.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao gravar o relatório: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if results.Failures[FailureFileLimit] > 0 {
+		fmt.Fprintf(os.Stderr, "Dica: %d requisições falharam por falta de descritores de arquivo (\"too many open files\"). Aumente o limite do sistema (ex: ulimit -n 65535) ou reduza -concurrency (atual: %d).\n", results.Failures[FailureFileLimit], config.Concurrency)
+	}
+	if config.HTTP3 && results.TotalRequests > 0 && results.Failures[FailureConnection] == results.TotalRequests {
+		fmt.Fprintln(os.Stderr, "Dica: nenhuma conexão HTTP/3 foi estabelecida. Confirme que o servidor aceita QUIC na porta UDP da URL (em geral anunciado pelo header Alt-Svc) e que o firewall não bloqueia UDP.")
+	}
+
+	if streamErr != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao gravar %s: %v\n", config.StreamJSONL, streamErr)
+		os.Exit(1)
+	}
+
+	if config.CSVFile != "" {
+		if err := writeCSV(config.CSVFile, results.Records); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao gravar CSV: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.HTMLFile != "" {
+		if err := writeHTMLReport(config.HTMLFile, config, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao gravar o HTML: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	failed := false
+	if config.Compare != "" {
+		metrics := compareResults(baseline, results)
+		label := config.Compare
+		if baseline.Name != "" {
+			label = fmt.Sprintf("%s (%s)", baseline.Name, config.Compare)
+		}
+		if regressions := printComparison(infoOutput(config), label, metrics, config.RegressionLimit, colors); len(regressions) > 0 {
+			fmt.Fprintln(os.Stderr, formatRegressions(regressions, config.RegressionLimit))
+			failed = true
+		}
+	}
+
+	// Todos os critérios são avaliados antes de sair, para que a execução
+	// mostre de uma vez tudo o que reprovou o teste.
+	if rate := successRate(results); rate < config.FailUnder {
+		fmt.Fprintf(os.Stderr, "Taxa de sucesso %.2f%% abaixo do mínimo de %.2f%%\n", rate, config.FailUnder)
+		failed = true
+	}
+
+	percentiles := []struct {
+		flag         string
+		value, limit time.Duration
+	}{
+		{"p50", results.P50Duration, config.AssertP50},
+		{"p90", results.P90Duration, config.AssertP90},
+		{"p95", results.P95Duration, config.AssertP95},
+		{"p99", results.P99Duration, config.AssertP99},
+	}
+	for _, p := range percentiles {
+		if p.limit > 0 && p.value > p.limit {
+			fmt.Fprintf(os.Stderr, "%s de %v acima do limite de %v (-assert-%s)\n", strings.ToUpper(p.flag), p.value, p.limit, p.flag)
+			failed = true
+		}
+	}
+
+	if failed || results.AbortReason != "" {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "regrava os arquivos de testdata com a saída atual")
+
+// goldenResults imita o resultado de uma execução padrão, com algumas
+// falhas, para cobrir as seções mais comuns da saída.
+func goldenResults() Results {
+	durations := slices.Concat(
+		slices.Repeat([]time.Duration{9 * time.Millisecond}, 20),
+		slices.Repeat([]time.Duration{17 * time.Millisecond}, 70),
+		slices.Repeat([]time.Duration{42 * time.Millisecond}, 10),
+	)
+	return Results{
+		TotalRequests:       100,
+		SuccessRequests:     97,
+		FailedRequests:      3,
+		TotalTime:           2 * time.Second,
+		AverageDuration:     18 * time.Millisecond,
+		StdDevDuration:      4 * time.Millisecond,
+		MinDuration:         9 * time.Millisecond,
+		MaxDuration:         42 * time.Millisecond,
+		P50Duration:         17 * time.Millisecond,
+		P90Duration:         24 * time.Millisecond,
+		P95Duration:         28 * time.Millisecond,
+		P99Duration:         40 * time.Millisecond,
+		AverageTTFB:         15 * time.Millisecond,
+		MinTTFB:             8 * time.Millisecond,
+		MaxTTFB:             39 * time.Millisecond,
+		P50TTFB:             14 * time.Millisecond,
+		P90TTFB:             21 * time.Millisecond,
+		P95TTFB:             25 * time.Millisecond,
+		P99TTFB:             37 * time.Millisecond,
+		AverageResponseSize: 128,
+		MinResponseSize:     64,
+		MaxResponseSize:     512,
+		P50ResponseSize:     128,
+		P90ResponseSize:     128,
+		P95ResponseSize:     256,
+		P99ResponseSize:     512,
+		StatusCodes:         map[int]int64{200: 97, 503: 3},
+		StatusSizes:         map[int]int64{200: 120, 503: 386},
+		Protocols:           map[string]int64{"HTTP/1.1": 100},
+		Failures:            map[FailureKind]int64{FailureStatus: 3},
+		Model:               "closed",
+		BytesReceived:       12800,
+		BytesDecoded:        12800,
+		BytesSent:           5400,
+		NewConns:            10,
+		ReusedConns:         90,
+		Histogram:           histogram(durations, nil),
+		Phases: []PhaseResults{
+			{Name: "dns", Requests: 10, AverageDuration: 300 * time.Microsecond},
+			{Name: "connect", Requests: 10, AverageDuration: 500 * time.Microsecond},
+			{Name: "tls"},
+			{Name: "server", Requests: 100, AverageDuration: 14 * time.Millisecond},
+			{Name: "transfer", Requests: 100, AverageDuration: 2 * time.Millisecond},
+		},
+	}
+}
+
+// TestPrintResultsGolden compara a saída em texto, sem cores, com
+// testdata/results.golden. Depois de mudar a saída de propósito, rode
+// go test -run TestPrintResultsGolden -update e revise o diff do arquivo.
+func TestPrintResultsGolden(t *testing.T) {
+	var out bytes.Buffer
+	printResults(&out, goldenResults(), palette{})
+
+	golden := filepath.Join("testdata", "results.golden")
+	if *update {
+		if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("saída diferente de %s:\n--- obtida ---\n%s\n--- esperada ---\n%s", golden, out.Bytes(), want)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// multipartBody é um body multipart/form-data montado uma única vez em
+// segmentos: os cabeçalhos das partes ficam em memória e o conteúdo dos
+// arquivos é lido do disco a cada requisição, sem carregá-los inteiros. Como
+// o tamanho de cada segmento é conhecido, o Content-Length é exato.
+type multipartBody struct {
+	contentType string
+	segments    []multipartSegment
+	size        int64
+	files       int
+}
+
+// multipartSegment é um trecho fixo (data) ou o conteúdo de um arquivo (path).
+type multipartSegment struct {
+	data []byte
+	path string
+}
+
+// newMultipartBody monta o body com os campos de -form e os arquivos de
+// -file, no formato campo=@caminho.
+func newMultipartBody(fields, files []string) (*multipartBody, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	body := &multipartBody{contentType: writer.FormDataContentType()}
+
+	flush := func() {
+		if buf.Len() > 0 {
+			body.segments = append(body.segments, multipartSegment{data: bytes.Clone(buf.Bytes())})
+			body.size += int64(buf.Len())
+			buf.Reset()
+		}
+	}
+
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("campo de formulário inválido %q, use key=value", field)
+		}
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range files {
+		field, path, ok := strings.Cut(file, "=")
+		path = strings.TrimPrefix(path, "@")
+		if !ok || field == "" || path == "" {
+			return nil, fmt.Errorf("arquivo inválido %q, use campo=@caminho", file)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler o arquivo %s: %v", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s não é um arquivo comum", path)
+		}
+
+		if _, err := writer.CreateFormFile(field, filepath.Base(path)); err != nil {
+			return nil, err
+		}
+		flush()
+		body.segments = append(body.segments, multipartSegment{path: path})
+		body.size += info.Size()
+		body.files++
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return body, nil
+}
+
+// open devolve um reader com o body completo. Os arquivos são abertos aqui e
+// fechados junto com o reader, que o transport fecha ao fim do envio.
+func (m *multipartBody) open() (io.ReadCloser, error) {
+	reader := &multipartReader{}
+	readers := make([]io.Reader, 0, len(m.segments))
+	for _, segment := range m.segments {
+		if segment.path == "" {
+			readers = append(readers, bytes.NewReader(segment.data))
+			continue
+		}
+		file, err := os.Open(segment.path)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		reader.files = append(reader.files, file)
+		readers = append(readers, file)
+	}
+	reader.Reader = io.MultiReader(readers...)
+	return reader, nil
+}
+
+type multipartReader struct {
+	io.Reader
+	files []*os.File
+}
+
+func (r *multipartReader) Close() error {
+	for _, file := range r.files {
+		file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func printJSONResults(w io.Writer, results Results) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// writeCSV grava uma linha por requisição no arquivo indicado.
+func writeCSV(path string, records []RequestRecord) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"index", "start", "duration_ms", "status_code", "error", "ttfb_ms", "response_bytes"})
+	for _, record := range records {
+		writer.Write([]string{
+			strconv.Itoa(record.Index),
+			record.Start.Format(time.RFC3339Nano),
+			strconv.FormatFloat(float64(record.Duration)/float64(time.Millisecond), 'f', 3, 64),
+			strconv.Itoa(record.StatusCode),
+			record.Error,
+			strconv.FormatFloat(float64(record.TTFB)/float64(time.Millisecond), 'f', 3, 64),
+			strconv.FormatInt(record.BytesDecoded, 10),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// printPrometheusResults escreve os resultados no formato de exposição de
+// texto do Prometheus, pronto para ser enviado a um Pushgateway. Com -name
+// todas as séries recebem o label run.
+func printPrometheusResults(w io.Writer, results Results) {
+	var run string
+	if results.Name != "" {
+		run = `run="` + labelEscaper.Replace(results.Name) + `"`
+	}
+	labels := func(extra string) string {
+		list := make([]string, 0, 2)
+		for _, label := range []string{run, extra} {
+			if label != "" {
+				list = append(list, label)
+			}
+		}
+		if len(list) == 0 {
+			return ""
+		}
+		return "{" + strings.Join(list, ",") + "}"
+	}
+
+	metric := func(name, kind, help string, value any) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s%s %v\n", name, help, name, kind, name, labels(""), value)
+	}
+	metric("stress_test_requests_total", "counter", "Total de requisições executadas.", results.TotalRequests)
+	metric("stress_test_requests_success_total", "counter", "Requisições bem-sucedidas.", results.SuccessRequests)
+	metric("stress_test_requests_failed_total", "counter", "Requisições que falharam.", results.FailedRequests)
+
+	metric("stress_test_requests_per_second", "gauge", "Vazão média do teste.", requestsPerSecond(results))
+
+	if len(results.StatusCodes) > 0 {
+		codes := make([]int, 0, len(results.StatusCodes))
+		for code := range results.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+
+		fmt.Fprintf(w, "# HELP stress_test_responses_total Respostas recebidas por status HTTP.\n")
+		fmt.Fprintf(w, "# TYPE stress_test_responses_total counter\n")
+		for _, code := range codes {
+			fmt.Fprintf(w, "stress_test_responses_total%s %d\n", labels(fmt.Sprintf(`code="%d"`, code)), results.StatusCodes[code])
+		}
+	}
+
+	// As faixas do Prometheus são cumulativas; a última faixa do histograma
+	// interno corresponde a +Inf.
+	name := "stress_test_request_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Duração das requisições.\n# TYPE %s histogram\n", name, name)
+	var cumulative int64
+	for _, bucket := range results.Histogram {
+		cumulative += bucket.Count
+		le := "+Inf"
+		if bucket.UpperBound > 0 {
+			le = strconv.FormatFloat(bucket.UpperBound.Seconds(), 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, labels(`le="`+le+`"`), cumulative)
+	}
+	// A soma vem do total acumulado pelo coletor, e não da média já
+	// arredondada vezes a contagem, para que _sum/_count seja a média exata.
+	fmt.Fprintf(w, "%s_sum%s %v\n%s_count%s %d\n", name, labels(""), results.SumDuration.Seconds(), name, labels(""), results.TotalRequests)
+}
+
+// labelEscaper escapa um valor de label do formato de texto do Prometheus.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
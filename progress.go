@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const progressInterval = time.Second
+
+// isTerminal informa se o arquivo está ligado a um terminal interativo.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startProgress exibe no stderr, sobrescrevendo sempre a mesma linha, o
+// andamento do teste. total é zero no modo por duração. A função devolvida
+// encerra a exibição e só retorna depois que a goroutine terminou.
+func startProgress(stats *collector, total int) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		var last int64
+		for {
+			select {
+			case <-ticker.C:
+				completed, success := stats.progress()
+				rps := float64(completed-last) / progressInterval.Seconds()
+				last = completed
+
+				rate := 0.0
+				if completed > 0 {
+					rate = float64(success) / float64(completed) * 100
+				}
+
+				if total > 0 {
+					fmt.Fprintf(os.Stderr, "\r\033[KConcluídas: %d/%d | Sucesso: %.2f%% | RPS: %.1f", completed, total, rate, rps)
+				} else {
+					fmt.Fprintf(os.Stderr, "\r\033[KConcluídas: %d | Sucesso: %.2f%% | RPS: %.1f", completed, rate, rps)
+				}
+			case <-done:
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// rng é a fonte de aleatoriedade de todo o teste (sorteio de passos, pausas
+// com -think-jitter), inicializada por -seed para que uma execução possa ser
+// repetida. *rand.Rand não é seguro para uso concorrente, daí o mutex.
+var (
+	rngMu sync.Mutex
+	rng   = rand.New(rand.NewPCG(0, 0))
+)
+
+func seedRandom(seed uint64) {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	rng = rand.New(rand.NewPCG(seed, seed))
+}
+
+// randIntN devolve um inteiro em [0, n).
+func randIntN(n int) int {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return rng.IntN(n)
+}
+
+// randInt64N devolve um inteiro em [0, n).
+func randInt64N(n int64) int64 {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return rng.Int64N(n)
+}
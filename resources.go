@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// resourceSampleInterval é o intervalo entre as amostras de goroutines e
+// memória; curto o bastante para pegar picos, barato o bastante para não
+// pesar na medição.
+const resourceSampleInterval = 100 * time.Millisecond
+
+// As amostras vêm de runtime/metrics, que ao contrário de
+// runtime.ReadMemStats não para o mundo a cada leitura.
+const (
+	metricHeap       = "/memory/classes/heap/objects:bytes"
+	metricGoroutines = "/sched/goroutines:goroutines"
+)
+
+// connCounter conta as conexões TCP abertas pelo client e o maior número
+// delas abertas ao mesmo tempo. Ao contrário das goroutines, o pico é exato:
+// é atualizado a cada conexão nova, não por amostragem.
+type connCounter struct {
+	active atomic.Int64
+	peak   atomic.Int64
+}
+
+// wrap devolve um DialContext que registra cada conexão aberta por dial.
+func (c *connCounter) wrap(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		active := c.active.Add(1)
+		for {
+			peak := c.peak.Load()
+			if active <= peak || c.peak.CompareAndSwap(peak, active) {
+				break
+			}
+		}
+		return &countedConn{Conn: conn, counter: c}, nil
+	}
+}
+
+// resetPeak recomeça a contagem do pico a partir das conexões abertas agora,
+// para que o aquecimento não entre no resultado.
+func (c *connCounter) resetPeak() {
+	c.peak.Store(c.active.Load())
+}
+
+// countedConn desconta a conexão do contador uma única vez, mesmo que Close
+// seja chamado mais de uma vez.
+type countedConn struct {
+	net.Conn
+	counter *connCounter
+	once    sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.counter.active.Add(-1) })
+	return c.Conn.Close()
+}
+
+// resourceSampler amostra periodicamente goroutines e memória do processo
+// durante o teste, guardando os picos.
+type resourceSampler struct {
+	conns *connCounter
+	stop  chan struct{}
+	done  chan struct{}
+
+	samples        []metrics.Sample
+	peakGoroutines int
+	peakHeap       uint64
+}
+
+// startResourceSampler começa a amostragem, ou devolve nil com -quiet.
+func startResourceSampler(config Config, conns *connCounter) *resourceSampler {
+	if config.Quiet {
+		return nil
+	}
+
+	conns.resetPeak()
+	s := &resourceSampler{
+		conns:   conns,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		samples: []metrics.Sample{{Name: metricGoroutines}, {Name: metricHeap}},
+	}
+	s.sample()
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(resourceSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.sample()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s *resourceSampler) sample() {
+	metrics.Read(s.samples)
+	s.peakGoroutines = max(s.peakGoroutines, int(s.samples[0].Value.Uint64()))
+	s.peakHeap = max(s.peakHeap, s.samples[1].Value.Uint64())
+}
+
+// finish encerra a amostragem e grava os picos nos resultados.
+func (s *resourceSampler) finish(results *Results) {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.sample()
+
+	results.PeakGoroutines = s.peakGoroutines
+	results.PeakHeap = s.peakHeap
+	results.PeakConns = s.conns.peak.Load()
+}
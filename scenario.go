@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scenario é uma mistura de requisições sorteadas de acordo com o peso de
+// cada passo. Com Sequence, cada worker é um usuário virtual que percorre
+// os passos na ordem do arquivo, recomeçando do primeiro ao chegar ao fim.
+type Scenario struct {
+	Sequence bool   `json:"sequence"`
+	Steps    []Step `json:"steps"`
+
+	totalWeight int
+}
+
+// Step é uma das requisições de um cenário.
+type Step struct {
+	Name    string         `json:"name"`
+	Method  string         `json:"method"`
+	URL     string         `json:"url"`
+	Headers map[string]any `json:"headers"`
+	Body    map[string]any `json:"body"`
+	Weight  int            `json:"weight"`
+
+	// ThinkTime e ThinkJitter substituem -think-time e -think-jitter na
+	// pausa depois deste passo.
+	ThinkTime   *jsonDuration `json:"thinkTime"`
+	ThinkJitter *jsonDuration `json:"thinkJitter"`
+
+	headers     map[string]any
+	body        RequestBody
+	thinkTime   time.Duration
+	thinkJitter time.Duration
+}
+
+// jsonDuration é uma duração escrita no JSON como texto, no formato de
+// time.ParseDuration ("1.5s", "300ms").
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duração deve ser um texto como \"1.5s\", recebido %s", data)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	if parsed < 0 {
+		return fmt.Errorf("duração negativa %q", text)
+	}
+	*d = jsonDuration(parsed)
+	return nil
+}
+
+// loadScenario lê o arquivo de cenário e prepara cada passo: os headers do
+// passo são somados aos headers globais (o passo tem prioridade, sem
+// diferenciar maiúsculas e minúsculas no nome), o body é
+// serializado uma única vez e a pausa sem valor no passo vem de -think-time e
+// -think-jitter.
+func loadScenario(path string, config Config, headers map[string]any) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o arquivo %s: %v", path, err)
+	}
+
+	var scenario Scenario
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&scenario); err != nil {
+		return nil, fmt.Errorf("erro ao fazer parse de %s: %v", path, err)
+	}
+	if len(scenario.Steps) == 0 {
+		return nil, fmt.Errorf("o cenário %s não tem passos", path)
+	}
+
+	for i := range scenario.Steps {
+		step := &scenario.Steps[i]
+		if step.URL == "" {
+			return nil, fmt.Errorf("o passo %d do cenário não tem url", i+1)
+		}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("passo-%d", i+1)
+		}
+		if step.Method == "" {
+			step.Method = http.MethodGet
+		}
+		if scenario.Sequence && step.Weight != 0 {
+			return nil, fmt.Errorf("o passo %q tem peso, mas um cenário em sequência não sorteia passos", step.Name)
+		}
+		if step.Weight == 0 {
+			step.Weight = 1
+		}
+		if step.Weight < 0 {
+			return nil, fmt.Errorf("o passo %q tem peso negativo", step.Name)
+		}
+
+		step.thinkTime, step.thinkJitter = config.ThinkTime, config.ThinkJitter
+		if step.ThinkTime != nil {
+			step.thinkTime = time.Duration(*step.ThinkTime)
+		}
+		if step.ThinkJitter != nil {
+			step.thinkJitter = time.Duration(*step.ThinkJitter)
+		}
+
+		step.headers = map[string]any{}
+		for key, value := range headers {
+			step.headers[key] = value
+		}
+		for key, value := range step.Headers {
+			setHeader(step.headers, key, value)
+		}
+
+		if len(step.Body) > 0 {
+			step.body = RequestBody{ContentType: config.ContentType}
+			step.body.Data, err = json.Marshal(step.Body)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao serializar o body do passo %q: %v", step.Name, err)
+			}
+			if step.body.ContentType == "" {
+				step.body.ContentType = "application/json"
+			}
+			if err := parseBodyTemplate(&step.body); err != nil {
+				return nil, fmt.Errorf("passo %q: %v", step.Name, err)
+			}
+		}
+
+		scenario.totalWeight += step.Weight
+	}
+
+	return &scenario, nil
+}
+
+// next devolve o passo de número n de um usuário virtual: em sequência, os
+// passos em ordem; nos demais cenários, um passo sorteado por peso.
+func (s *Scenario) next(n int) *Step {
+	if s.Sequence {
+		return &s.Steps[n%len(s.Steps)]
+	}
+	return s.pick()
+}
+
+// hasThinkTime informa se algum passo pausa depois de executado.
+func (s *Scenario) hasThinkTime() bool {
+	for _, step := range s.Steps {
+		if step.thinkTime > 0 || step.thinkJitter > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pick sorteia um passo com probabilidade proporcional ao seu peso.
+func (s *Scenario) pick() *Step {
+	n := randIntN(s.totalWeight)
+	for i := range s.Steps {
+		n -= s.Steps[i].Weight
+		if n < 0 {
+			return &s.Steps[i]
+		}
+	}
+	return &s.Steps[len(s.Steps)-1]
+}
+
+// pause sorteia a pausa depois do passo, como thinkTime faz com as flags.
+func (s *Step) pause() time.Duration {
+	return jitteredPause(s.thinkTime, s.thinkJitter)
+}
+
+// describe resume o passo para o início do teste e para -dry-run.
+func (s *Step) describe(sequence bool) string {
+	var details []string
+	if !sequence {
+		details = append(details, fmt.Sprintf("peso %d", s.Weight))
+	}
+	if s.thinkJitter > 0 {
+		details = append(details, fmt.Sprintf("pausa %v ±%v", s.thinkTime, s.thinkJitter))
+	} else if s.thinkTime > 0 {
+		details = append(details, fmt.Sprintf("pausa %v", s.thinkTime))
+	}
+	description := fmt.Sprintf("%s: %s %s", s.Name, s.Method, s.URL)
+	if len(details) > 0 {
+		description += " (" + strings.Join(details, ", ") + ")"
+	}
+	return description
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// smokeCapture é a requisição enviada com -smoke e a resposta recebida,
+// guardadas por sendRequest para serem exibidas por inteiro.
+type smokeCapture struct {
+	req      *http.Request
+	reqBody  []byte
+	resp     *http.Response
+	respBody []byte
+}
+
+// runSmoke envia uma única requisição, a primeira que o teste enviaria, e
+// escreve em w a requisição, a resposta completa e o tempo de cada fase.
+// Devolve o erro da requisição, se ela falhou.
+func runSmoke(ctx context.Context, w io.Writer, requester *requester, config Config, headers map[string]any, body RequestBody, scenario *Scenario, data *dataset, urls []string) error {
+	capture := &smokeCapture{}
+	requester.capture = capture
+
+	var result RequestResult
+	var err error
+	if scenario != nil {
+		step := scenario.next(0)
+		stepConfig := config
+		stepConfig.URL = step.URL
+		stepConfig.Method = step.Method
+		fmt.Fprintf(w, "Passo do cenário: %s\n", step.Name)
+		result, err = requester.makeRequest(ctx, stepConfig, step.headers, step.body, data.row(0))
+	} else {
+		reqConfig, reqBody := pickMethod(targetConfig(config, urls, 0), body.variant(0))
+		result, err = requester.makeRequest(ctx, reqConfig, headers, reqBody, data.row(0))
+	}
+
+	if req := capture.req; req != nil {
+		fmt.Fprintf(w, "> %s %s\n", req.Method, req.URL)
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		fmt.Fprintf(w, "> Host: %s\n", host)
+		writeMessageHeaders(w, "> ", req.Header)
+		if encoding := req.Header.Get("Content-Encoding"); encoding != "" && len(capture.reqBody) > 0 {
+			fmt.Fprintf(w, ">\n(%d bytes comprimidos com %s)\n", len(capture.reqBody), encoding)
+		} else if len(capture.reqBody) > 0 {
+			fmt.Fprintf(w, ">\n%s\n", strings.TrimRight(string(capture.reqBody), "\n"))
+		}
+		fmt.Fprintln(w)
+	}
+	if resp := capture.resp; resp != nil && result.StatusCode != 0 {
+		fmt.Fprintf(w, "< %s %s\n", resp.Proto, resp.Status)
+		writeMessageHeaders(w, "< ", resp.Header)
+		if len(capture.respBody) > 0 {
+			fmt.Fprintf(w, "<\n%s\n", strings.TrimRight(string(capture.respBody), "\n"))
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "Tempo total: %v", result.Duration)
+	if result.TTFB > 0 {
+		fmt.Fprintf(w, " (TTFB %v)", result.TTFB)
+	}
+	fmt.Fprintln(w)
+	for phase, d := range result.Phases {
+		if d > 0 {
+			fmt.Fprintf(w, "  %-14s %12v\n", phaseLabels[phase]+":", d)
+		}
+	}
+	if result.Retries > 0 {
+		fmt.Fprintf(w, "Novas tentativas: %d\n", result.Retries)
+	}
+	return err
+}
+
+// writeMessageHeaders escreve os headers em ordem alfabética, um por linha,
+// com o prefixo que indica a direção, como no curl -v, e as credenciais
+// ocultas.
+func writeMessageHeaders(w io.Writer, prefix string, header http.Header) {
+	header = redactHeaders(header)
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[key] {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, key, value)
+		}
+	}
+}
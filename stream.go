@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// requestEvent é a linha de -stream-jsonl escrita para cada requisição
+// concluída.
+type requestEvent struct {
+	Timestamp     time.Time     `json:"timestamp"`
+	Index         int           `json:"index"`
+	Worker        int           `json:"worker"`
+	Method        string        `json:"method"`
+	URL           string        `json:"url"`
+	Step          string        `json:"step,omitempty"`
+	StatusCode    int           `json:"status_code"`
+	Duration      time.Duration `json:"duration_ns"`
+	TTFB          time.Duration `json:"ttfb_ns"`
+	BytesReceived int64         `json:"bytes_received"`
+	BytesSent     int64         `json:"bytes_sent"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// eventStream grava os eventos em JSON Lines à medida que as requisições
+// terminam. Os workers só enviam para um canal com buffer; uma única
+// goroutine escreve no arquivo, esvaziando o buffer de escrita sempre que o
+// canal fica vazio, para que quem acompanha o arquivo veja os eventos logo.
+type eventStream struct {
+	events chan requestEvent
+	done   chan struct{}
+	file   *os.File
+	err    error
+}
+
+func newEventStream(path string) (*eventStream, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &eventStream{
+		events: make(chan requestEvent, 1024),
+		done:   make(chan struct{}),
+		file:   file,
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *eventStream) run() {
+	defer close(s.done)
+
+	writer := bufio.NewWriter(s.file)
+	encoder := json.NewEncoder(writer)
+	for event := range s.events {
+		if s.err != nil {
+			continue
+		}
+		if s.err = encoder.Encode(event); s.err == nil && len(s.events) == 0 {
+			s.err = writer.Flush()
+		}
+	}
+
+	if err := writer.Flush(); s.err == nil {
+		s.err = err
+	}
+	if err := s.file.Close(); s.err == nil {
+		s.err = err
+	}
+}
+
+// send registra o resultado da requisição index feita pelo worker. Com o
+// buffer do canal cheio, o worker espera a escrita em vez de perder eventos.
+func (s *eventStream) send(worker, index int, result RequestResult, err error) {
+	if s == nil {
+		return
+	}
+	event := requestEvent{
+		Timestamp:     result.Start,
+		Index:         index,
+		Worker:        worker,
+		Method:        result.Method,
+		URL:           result.URL,
+		Step:          result.Step,
+		StatusCode:    result.StatusCode,
+		Duration:      result.Duration,
+		TTFB:          result.TTFB,
+		BytesReceived: result.BytesReceived,
+		BytesSent:     result.BytesSent,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.events <- event
+}
+
+// close espera a goroutine de escrita gravar todos os eventos pendentes e
+// devolve o primeiro erro de escrita, se houver. Não pode haver send depois.
+func (s *eventStream) close() error {
+	if s == nil {
+		return nil
+	}
+	close(s.events)
+	<-s.done
+	return s.err
+}
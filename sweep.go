@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SweepLevel é o resultado de uma das execuções de -concurrency-sweep.
+type SweepLevel struct {
+	Concurrency int     `json:"concurrency"`
+	Results     Results `json:"results"`
+}
+
+// runSweep executa o teste uma vez para cada concorrência de
+// -concurrency-sweep, com as mesmas demais opções. As conexões ociosas são
+// fechadas entre os níveis para que cada um comece do zero, e com
+// -sweep-cooldown o servidor ganha uma pausa entre eles. Uma interrupção
+// encerra a varredura com os níveis já concluídos.
+func runSweep(ctx context.Context, requester *requester, config Config, headers map[string]any, body RequestBody, scenario *Scenario, data *dataset, urls []string) []SweepLevel {
+	info := infoOutput(config)
+	levels := make([]SweepLevel, 0, len(config.ConcurrencySweep))
+
+	for i, concurrency := range config.ConcurrencySweep {
+		if i > 0 && config.SweepCooldown > 0 {
+			fmt.Fprintf(info, "Pausa de %v antes da próxima concorrência\n\n", config.SweepCooldown)
+			if !sleepContext(ctx, config.SweepCooldown) {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		levelConfig := config
+		levelConfig.Concurrency = concurrency
+		if config.RequestsPerWorker > 0 {
+			levelConfig.Requests = config.RequestsPerWorker * concurrency
+		}
+
+		requester.client.CloseIdleConnections()
+		results, _ := runStressTest(ctx, requester, levelConfig, headers, body, scenario, data, urls, nil)
+		levels = append(levels, SweepLevel{Concurrency: concurrency, Results: results})
+		fmt.Fprintf(info, "Concorrência %d concluída: %.1f req/s, P95 %v, %.2f%% de erros\n\n", concurrency, requestsPerSecond(results), results.P95Duration, errorRate(results))
+	}
+
+	return levels
+}
+
+// printSweep mostra uma linha por concorrência, para ver como vazão e
+// latência escalam.
+func printSweep(w io.Writer, levels []SweepLevel, colors palette) {
+	fmt.Fprintln(w, "\n"+colors.bold("=== Varredura de concorrência ==="))
+	fmt.Fprintf(w, "  %s %s %s %s %s %s\n", pad("Concorrência", 12), pad("Req/s", 10), pad("Média", 12), pad("P95", 12), pad("P99", 12), pad("Erros", 8))
+	for _, level := range levels {
+		results := level.Results
+		fmt.Fprintf(w, "  %s %s %s %s %s %s\n",
+			pad(fmt.Sprint(level.Concurrency), 12),
+			pad(fmt.Sprintf("%.1f", requestsPerSecond(results)), 10),
+			pad(results.AverageDuration.String(), 12),
+			pad(results.P95Duration.String(), 12),
+			pad(results.P99Duration.String(), 12),
+			colors.failures(pad(fmt.Sprintf("%.2f%%", errorRate(results)), 8), results.FailedRequests))
+	}
+}
+
+// printSweepJSON escreve todos os níveis, cada um com o resultado completo.
+func printSweepJSON(w io.Writer, name string, levels []SweepLevel) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Name   string       `json:"name,omitempty"`
+		Levels []SweepLevel `json:"levels"`
+	}{name, levels})
+}
+
+func errorRate(results Results) float64 {
+	if results.TotalRequests == 0 {
+		return 0
+	}
+	return 100 - successRate(results)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateVars são as variáveis disponíveis nos templates da URL e do body.
+type templateVars struct {
+	// RequestID é um número sequencial único no teste, a partir de 1.
+	RequestID int64
+	// UUID é um UUID v4 aleatório gerado para a requisição.
+	UUID string
+	// Timestamp é o instante da requisição em milissegundos Unix.
+	Timestamp int64
+	// Data é a linha de -data atribuída à requisição.
+	Data map[string]string
+}
+
+// templateFuncs complementam as funções nativas de text/template, como
+// urlquery, com as que os bodies JSON precisam.
+var templateFuncs = template.FuncMap{
+	"json": jsonEscape,
+}
+
+// jsonEscape escapa o texto para uso dentro de uma string JSON, sem as aspas
+// externas.
+func jsonEscape(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+// parseTemplate compila text como template, ou devolve nil quando o texto não
+// tem nenhum "{{" e pode ser enviado como está, sem custo extra.
+func parseTemplate(name, text string) (*template.Template, error) {
+	if !strings.Contains(text, "{{") {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template %s inválido: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// parseBodyTemplate prepara o body para ser renderizado a cada requisição.
+func parseBodyTemplate(body *RequestBody) error {
+	tmpl, err := parseTemplate("do body", string(body.Data))
+	if err != nil {
+		return err
+	}
+	body.template = tmpl
+	return nil
+}
+
+// render devolve uma cópia do body com o template executado para as
+// variáveis informadas.
+func (b RequestBody) render(vars templateVars) (RequestBody, error) {
+	var buf bytes.Buffer
+	if err := b.template.Execute(&buf, vars); err != nil {
+		return b, fmt.Errorf("erro ao renderizar o body: %v", err)
+	}
+	b.Data = buf.Bytes()
+	return b, nil
+}
+
+func newTemplateVars(id int64, row map[string]string) templateVars {
+	return templateVars{
+		RequestID: id,
+		UUID:      newUUID(),
+		Timestamp: time.Now().UnixMilli(),
+		Data:      row,
+	}
+}
+
+// renderRequest aplica vars ao template da URL, se houver, e ao do body.
+func (r *requester) renderRequest(config Config, body RequestBody, vars templateVars) (Config, RequestBody, error) {
+	if tmpl := r.urls[config.URL]; tmpl != nil {
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, vars); err != nil {
+			return config, body, fmt.Errorf("erro ao renderizar a URL: %v", err)
+		}
+		config.URL = buf.String()
+	}
+
+	if body.template != nil {
+		var err error
+		body, err = body.render(vars)
+		if err != nil {
+			return config, body, err
+		}
+	}
+
+	return config, body, nil
+}
+
+// addURLTemplate compila a URL para ser renderizada a cada requisição. As
+// URLs são preparadas antes do teste e o mapa só é lido durante ele.
+func (r *requester) addURLTemplate(rawURL string) error {
+	tmpl, err := parseTemplate("da URL", rawURL)
+	if err != nil || tmpl == nil {
+		return err
+	}
+	if r.urls == nil {
+		r.urls = map[string]*template.Template{}
+	}
+	r.urls[rawURL] = tmpl
+	return nil
+}
+
+// hasTemplate informa se a requisição precisa ser renderizada.
+func (r *requester) hasTemplate(config Config, body RequestBody) bool {
+	return body.template != nil || r.urls[config.URL] != nil
+}
+
+// checkTemplates renderiza uma vez cada requisição possível, revelando antes
+// do teste erros como uma coluna de -data com o nome errado.
+func checkTemplates(r *requester, config Config, body RequestBody, scenario *Scenario, data *dataset, urls []string) error {
+	vars := newTemplateVars(0, data.row(0))
+	if scenario == nil {
+		for i := range max(len(urls), len(body.variants), 1) {
+			if _, _, err := r.renderRequest(targetConfig(config, urls, i), body.variant(i), vars); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, step := range scenario.Steps {
+		stepConfig := config
+		stepConfig.URL = step.URL
+		if _, _, err := r.renderRequest(stepConfig, step.body, vars); err != nil {
+			return fmt.Errorf("passo %q: %v", step.Name, err)
+		}
+	}
+	return nil
+}
+
+// newUUID gera um UUID versão 4 (RFC 9562).
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Fases em que a duração de uma requisição é dividida.
+const (
+	phaseDNS = iota
+	phaseConnect
+	phaseTLS
+	phaseServer
+	phaseTransfer
+	phaseCount
+)
+
+var phaseNames = [phaseCount]string{"dns", "connect", "tls", "server", "transfer"}
+
+var phaseLabels = [phaseCount]string{"DNS", "Conexão TCP", "Handshake TLS", "Servidor", "Transferência"}
+
+// phaseDurations guarda o tempo gasto em cada fase; zero indica que a fase não
+// aconteceu, como DNS, conexão e TLS quando a conexão veio do pool.
+type phaseDurations [phaseCount]time.Duration
+
+// connReuse indica se a requisição usou uma conexão nova ou do pool.
+type connReuse int8
+
+const (
+	connUnknown connReuse = iota // nenhuma conexão chegou a ser obtida
+	connNew
+	connReused
+)
+
+// requestTrace mede as fases de uma requisição pelos hooks do
+// httptrace. Os hooks de conexão podem rodar em goroutines do transport,
+// por isso o estado é protegido pelo mutex. Com redirecionamentos, as fases
+// de cada salto são somadas e o primeiro byte é o da última resposta.
+type requestTrace struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wrote        time.Time
+	firstByte    time.Time
+	phases       phaseDurations
+	conn         connReuse
+}
+
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	// since soma à fase o tempo desde *from, se a fase tiver começado.
+	since := func(phase int, from *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if !from.IsZero() {
+			t.phases[phase] += time.Since(*from)
+			*from = time.Time{}
+		}
+	}
+	mark := func(at *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		*at = time.Now()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { since(phaseDNS, &t.dnsStart) },
+		// Com vários endereços o dialer pode tentar mais de uma conexão; vale
+		// o tempo desde a primeira tentativa até a que vingou.
+		ConnectStart: func(_, _ string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				since(phaseConnect, &t.connectStart)
+			}
+		},
+		// Com redirecionamentos ou o handshake de -auth negotiate a
+		// requisição faz várias idas ao servidor; basta uma delas abrir uma
+		// conexão para que a requisição conte como conexão nova.
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if !info.Reused {
+				t.conn = connNew
+			} else if t.conn == connUnknown {
+				t.conn = connReused
+			}
+		},
+		TLSHandshakeStart: func() { mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { since(phaseTLS, &t.tlsStart) },
+		WroteRequest:      func(httptrace.WroteRequestInfo) { mark(&t.wrote) },
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.firstByte = time.Now()
+			if !t.wrote.IsZero() {
+				t.phases[phaseServer] += t.firstByte.Sub(t.wrote)
+				t.wrote = time.Time{}
+			}
+		},
+	}
+}
+
+// record preenche o TTFB, as fases e o reuso de conexão do resultado,
+// medidos a partir de start.
+// Deve ser chamado depois que o body da resposta foi lido.
+func (t *requestTrace) record(result *RequestResult, start time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result.Phases = t.phases
+	result.Conn = t.conn
+	if !t.firstByte.IsZero() {
+		result.TTFB = t.firstByte.Sub(start)
+		result.Phases[phaseTransfer] = result.Duration - result.TTFB
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// newRequestLogger devolve o logger de -verbose/-vv, ou nil sem eles.
+// log.Logger serializa as escritas, então cada entrada sai inteira mesmo com
+// várias goroutines registrando ao mesmo tempo.
+func newRequestLogger(config Config) *log.Logger {
+	if !config.Verbose && !config.VeryVerbose {
+		return nil
+	}
+	return log.New(os.Stderr, "", log.Ltime|log.Lmicroseconds)
+}
+
+// logRequest registra uma tentativa: método, URL, status e duração e, com
+// -vv, os headers da requisição e da resposta, com as credenciais ocultas
+// para não irem parar nos logs de CI.
+func (r *requester) logRequest(req *http.Request, resp *http.Response, result RequestResult, err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s -> ", req.Method, req.URL)
+	if result.StatusCode != 0 {
+		fmt.Fprintf(&b, "%d ", result.StatusCode)
+	}
+	fmt.Fprintf(&b, "(%v)", result.Duration)
+	if err != nil {
+		fmt.Fprintf(&b, " erro: %v", err)
+	}
+
+	if r.headers {
+		writeHeaders(&b, "> ", redactHeaders(req.Header))
+		if resp != nil {
+			writeHeaders(&b, "< ", redactHeaders(resp.Header))
+		}
+	}
+
+	r.logger.Print(b.String())
+}
+
+func writeHeaders(b *strings.Builder, prefix string, header http.Header) {
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[key] {
+			fmt.Fprintf(b, "\n  %s%s: %s", prefix, key, value)
+		}
+	}
+}
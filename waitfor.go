@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// waitForInterval é a pausa entre duas verificações de -wait-for.
+const waitForInterval = time.Second
+
+// waitForReady consulta -wait-for até que responda com um status 2xx, para
+// que o teste só comece com o serviço pronto. Devolve quanto tempo e quantas
+// tentativas foram necessárias, ou um erro com o último resultado se
+// -wait-timeout passar antes. A verificação usa um client próprio, sem
+// -unix-socket, -host, -http3 nem cookies, que valem só para as URLs
+// testadas, e não entra nos resultados.
+func waitForReady(ctx context.Context, config Config) (time.Duration, int, error) {
+	config.UnixSocket = ""
+	config.Host = ""
+	config.HTTP3 = false
+	config.EnableCookies = false
+	config.Cookies = nil
+	config.FollowRedirects = true
+	client, err := newHTTPClient(config, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer client.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(ctx, config.WaitTimeout)
+	defer cancel()
+
+	start := time.Now()
+	var last error
+	for attempts := 1; ; attempts++ {
+		err := probe(ctx, client, config.WaitFor)
+		if err == nil {
+			return time.Since(start), attempts, nil
+		}
+		// A tentativa cortada pelo próprio -wait-timeout não diz nada sobre
+		// o serviço; o erro útil é o da anterior.
+		if ctx.Err() == nil || last == nil {
+			last = err
+		}
+		if !sleepContext(ctx, waitForInterval) {
+			return time.Since(start), attempts, fmt.Errorf("%s não ficou disponível em %v (última tentativa: %v)", config.WaitFor, config.WaitTimeout, last)
+		}
+	}
+}
+
+// probe faz uma verificação de -wait-for; só um status 2xx conta como pronto.
+func probe(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
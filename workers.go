@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// stragglerFactor define quando um worker destoa dos demais: com menos da
+// metade das requisições da mediana ou com a latência média acima do dobro
+// da mediana.
+const stragglerFactor = 2
+
+// WorkerStats resume as requisições de um worker (-per-worker-stats).
+type WorkerStats struct {
+	Worker          int           `json:"worker"`
+	Requests        int64         `json:"requests"`
+	FailedRequests  int64         `json:"failed_requests"`
+	AverageDuration time.Duration `json:"average_duration_ns"`
+	MaxDuration     time.Duration `json:"max_duration_ns"`
+	Straggler       bool          `json:"straggler"`
+}
+
+// workerSlots reserva os totais de cada worker, ou devolve nil sem
+// -per-worker-stats.
+func workerSlots(config Config) []workerTotals {
+	if !config.PerWorkerStats {
+		return nil
+	}
+	return make([]workerTotals, config.Concurrency)
+}
+
+type workerTotals struct {
+	requests    int64
+	failed      int64
+	totalTime   time.Duration
+	maxDuration time.Duration
+}
+
+// workerResults converte os totais de cada worker e marca os que destoam da
+// mediana. Workers que não chegaram a fazer nenhuma requisição, como os que
+// ainda esperavam o -rampup, também são listados.
+func workerResults(totals []workerTotals) []WorkerStats {
+	if len(totals) == 0 {
+		return nil
+	}
+
+	workers := make([]WorkerStats, len(totals))
+	requests := make([]int64, len(totals))
+	var averages []time.Duration
+	for i, t := range totals {
+		workers[i] = WorkerStats{Worker: i, Requests: t.requests, FailedRequests: t.failed, MaxDuration: t.maxDuration}
+		if t.requests > 0 {
+			workers[i].AverageDuration = t.totalTime / time.Duration(t.requests)
+			averages = append(averages, workers[i].AverageDuration)
+		}
+		requests[i] = t.requests
+	}
+
+	slices.Sort(requests)
+	slices.Sort(averages)
+	medianRequests := requests[len(requests)/2]
+	var medianAverage time.Duration
+	if len(averages) > 0 {
+		medianAverage = averages[len(averages)/2]
+	}
+	for i := range workers {
+		w := &workers[i]
+		w.Straggler = w.Requests*stragglerFactor < medianRequests ||
+			(w.Requests > 0 && w.AverageDuration > medianAverage*stragglerFactor)
+	}
+	return workers
+}